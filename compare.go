@@ -0,0 +1,152 @@
+package liquid
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Comparisons follow ruby semantics rather than Go's: integers and floats are
+// compared numerically regardless of their width, values that can't be ordered
+// (nil, booleans, arrays, hashes) are never less or greater than anything, and
+// ordering a string against a number is an ArgumentError.
+
+// toFloat returns the numeric value of integer and float expressions
+func toFloat(e Expression) (float64, bool) {
+	switch e.(type) {
+	case integerExpr:
+		return float64(e.(integerExpr)), true
+	case floatExpr:
+		return float64(e.(floatExpr)), true
+	}
+	return 0, false
+}
+
+// liquidEqual is the analog to ruby's ==, numbers are equal if their
+// values match, and arrays and hashes are equal if all their contents are
+func liquidEqual(a, b Expression) bool {
+	if x, ok := a.(integerExpr); ok {
+		if y, ok := b.(integerExpr); ok {
+			return x == y
+		}
+	}
+
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+
+	switch a.(type) {
+	case nil, nilExpr:
+		return b == nil || b == Nil
+	case arrayExpr:
+		x := a.(arrayExpr)
+		y, ok := b.(arrayExpr)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !liquidEqual(interfaceToExpression(x[i]), interfaceToExpression(y[i])) {
+				return false
+			}
+		}
+		return true
+	case hashExpr:
+		x := a.(hashExpr)
+		y, ok := b.(hashExpr)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			other, ok := y[k]
+			if !ok || !liquidEqual(interfaceToExpression(v), interfaceToExpression(other)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if b == nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// compare returns -1, 0 or 1 as a is less than, equal to or greater than b. If the
+// operands can't be ordered ok is false, and err is set if they can't be compared
+func compare(a, b Expression) (result int, ok bool, err error) {
+	if x, isInt := a.(integerExpr); isInt {
+		if y, isInt := b.(integerExpr); isInt {
+			return compareOrdered(x < y, x > y), true, nil
+		}
+	}
+
+	x, aIsNumber := toFloat(a)
+	y, bIsNumber := toFloat(b)
+	if aIsNumber && bIsNumber {
+		return compareOrdered(x < y, x > y), true, nil
+	}
+
+	s, aIsString := a.(stringExpr)
+	t, bIsString := b.(stringExpr)
+	if aIsString && bIsString {
+		return strings.Compare(string(s), string(t)), true, nil
+	}
+
+	if (aIsNumber || aIsString) && (bIsNumber || bIsString) {
+		return 0, false, argumentError("comparison of %v with %v failed", rubyClass(a), rubyInspect(b))
+	}
+
+	return 0, false, nil
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// rubyClass names the ruby class of an expression, for use in error messages
+func rubyClass(e Expression) string {
+	switch e.(type) {
+	case nil, nilExpr:
+		return "NilClass"
+	case boolExpr:
+		if e.(boolExpr) {
+			return "TrueClass"
+		}
+		return "FalseClass"
+	case stringExpr:
+		return "String"
+	case integerExpr:
+		return "Integer"
+	case floatExpr:
+		return "Float"
+	case arrayExpr:
+		return "Array"
+	case hashExpr:
+		return "Hash"
+	case rangeExpr:
+		return "Range"
+	}
+	return reflect.TypeOf(e).String()
+}
+
+// rubyInspect mimics the way ruby describes the right hand side of a failed
+// comparison: immediate values like numbers are inspected, anything else is
+// described by its class
+func rubyInspect(e Expression) string {
+	switch e.(type) {
+	case integerExpr, floatExpr:
+		return toString(e)
+	case nil, nilExpr:
+		return "nil"
+	case boolExpr:
+		return e.Name()
+	}
+	return rubyClass(e)
+}
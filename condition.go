@@ -2,30 +2,17 @@ package liquid
 
 import (
 	"fmt"
-	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// ErrInvalidOperator wraps an invalid variable operator error
-type ErrInvalidOperator string
-
-func (e ErrInvalidOperator) Error() string {
-	return fmt.Sprintf("Liquid::InvalidOperator: %v", string(e))
-}
-
-// ErrBadArgument wraps an argument list error
-type ErrBadArgument struct {
-	args []Expression
-}
-
-func (e ErrBadArgument) Error() string {
-	return fmt.Sprintf("Liquid::ArgumentError: %v", e.args)
-}
-
 // Condition is a callable func that wraps an operator
 type Condition struct {
 	a        Expression
-	operator operator
+	operator string
 	b        Expression
 	or       []*Condition
 	and      []*Condition
@@ -33,7 +20,7 @@ type Condition struct {
 
 // Evaluate the supplied condition
 func (c *Condition) Evaluate(ctx Context) (bool, error) {
-	result, err := c.operator(c.a.Evaluate(ctx), c.b.Evaluate(ctx))
+	result, err := c.interpret(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -78,10 +65,66 @@ func (c *Condition) And(op1 Expression, operator string, op2 Expression) error {
 	return nil
 }
 
+func (c *Condition) interpret(ctx Context) (bool, error) {
+	// If the operator is empty this means that the decision statement is just
+	// a single variable. We can just poll this variable from the context and
+	// return this as the result.
+	if c.operator == "" {
+		if c.a == nil {
+			return false, nil
+		}
+		a, err := c.a.Evaluate(ctx)
+		if err != nil {
+			return false, err
+		}
+		return isTruthy(a), nil
+	}
+
+	operation, ok := ctx.engine.orDefault().findOperator(c.operator)
+	if !ok {
+		return false, argumentError("Unknown operator %v", c.operator)
+	}
+
+	a, err := c.a.Evaluate(ctx)
+	if err != nil {
+		return false, err
+	}
+	b, err := c.b.Evaluate(ctx)
+	if err != nil {
+		return false, err
+	}
+	return operation(a, b)
+}
+
+// isTruthy follows the Liquid rules for truthiness: only nil and false are
+// falsy, everything else (including 0, "" and empty arrays) is truthy. Go
+// values that are nil, such as typed nil pointers, nil slices and nil maps,
+// are converted to nil by the Context and so are also falsy
+func isTruthy(e Expression) bool {
+	switch e.(type) {
+	case nil, nilExpr:
+		return false
+	case boolExpr:
+		return bool(e.(boolExpr))
+	}
+	return true
+}
+
 type operator func(a, b Expression) (bool, error)
 
+// equal is the analog to equal_variables, which gives MethodLiterals (blank
+// and empty) a chance to inspect the opposite operand. Operands that don't
+// respond to the method compare as nil, so they are never equal
 func equal(a, b Expression) (bool, error) {
-	return reflect.DeepEqual(a, b), nil
+	if method, ok := a.(methodLiteralExpr); ok {
+		return method.send(b), nil
+	}
+
+	if method, ok := b.(methodLiteralExpr); ok {
+		return method.send(a), nil
+	}
+
+	return liquidEqual(a, b), nil
 }
 
 func notEqual(a, b Expression) (bool, error) {
@@ -90,31 +133,23 @@ func notEqual(a, b Expression) (bool, error) {
 }
 
 func lt(a, b Expression) (bool, error) {
-	if t := reflect.TypeOf(a); t == reflect.TypeOf(b) {
-		switch a.(type) {
-		case integerExpr:
-			return a.(integerExpr) < b.(integerExpr), nil
-		case floatExpr:
-			return a.(floatExpr) < b.(floatExpr), nil
-		case stringExpr:
-			return a.(stringExpr) < b.(stringExpr), nil
-		}
-	}
-	return false, ErrBadArgument{}
+	result, ok, err := compare(a, b)
+	return ok && result < 0, err
+}
+
+func lte(a, b Expression) (bool, error) {
+	result, ok, err := compare(a, b)
+	return ok && result <= 0, err
 }
 
 func gt(a, b Expression) (bool, error) {
-	if t := reflect.TypeOf(a); t == reflect.TypeOf(b) {
-		switch a.(type) {
-		case integerExpr:
-			return a.(integerExpr) > b.(integerExpr), nil
-		case floatExpr:
-			return a.(floatExpr) > b.(floatExpr), nil
-		case stringExpr:
-			return a.(stringExpr) > b.(stringExpr), nil
-		}
-	}
-	return false, ErrBadArgument{}
+	result, ok, err := compare(a, b)
+	return ok && result > 0, err
+}
+
+func gte(a, b Expression) (bool, error) {
+	result, ok, err := compare(a, b)
+	return ok && result >= 0, err
 }
 
 func contains(a, b Expression) (bool, error) {
@@ -122,7 +157,7 @@ func contains(a, b Expression) (bool, error) {
 	switch a.(type) {
 	case arrayExpr:
 		for _, value := range a.(arrayExpr) {
-			if reflect.DeepEqual(interfaceToExpression(value), b) {
+			if liquidEqual(interfaceToExpression(value), b) {
 				return true, nil
 			}
 		}
@@ -134,37 +169,100 @@ func contains(a, b Expression) (bool, error) {
 	return false, nil
 }
 
-var operators = map[string]operator{
-	"==": equal,
-	"!=": notEqual,
-	"<>": notEqual,
-	"<":  lt,
-	">":  gt,
-	">=": func(a, b Expression) (bool, error) {
-		if g, err := gt(a, b); err != nil || g {
-			return g, err
+var (
+	// standardOperators are the operators every engine has, they're never changed
+	standardOperators = map[string]operator{
+		"==":       equal,
+		"!=":       notEqual,
+		"<>":       notEqual,
+		"<":        lt,
+		">":        gt,
+		">=":       gte,
+		"<=":       lte,
+		"contains": contains,
+	}
+	// standardOperatorRegexp is used by the Lexer to find comparison operators
+	standardOperatorRegexp = buildOperatorRegexp(standardOperators)
+)
+
+// OperatorFunc is the signature of a custom comparison operator. The operands
+// are evaluated before being passed in, so they are plain Go values (string, int,
+// float64, bool, nil, []interface{}, map[string]interface{}, Drops etc.)
+type OperatorFunc func(left, right interface{}) (bool, error)
+
+// RegisterOperator makes a new infix operator available to conditions, so after
+//
+//	RegisterOperator("startswith", func(left, right interface{}) (bool, error) {
+//		l, _ := left.(string)
+//		r, _ := right.(string)
+//		return strings.HasPrefix(l, r), nil
+//	})
+//
+// templates parsed with ParseTemplate can use `{% if title startswith 'Sale' %}`.
+// Registering an existing name replaces that operator. Operators which are words take
+// precedence over identifiers, so they can't also be used as variable names. It
+// registers the operator on the default engine, other engines don't see it, see
+// Engine.RegisterOperator
+func RegisterOperator(name string, fn OperatorFunc) {
+	defaultEngine.RegisterOperator(name, fn)
+}
+
+func mustOperator(name string, fn OperatorFunc) operator {
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		panic(fmt.Sprintf("liquid: invalid operator name %q", name))
+	}
+	if fn == nil {
+		panic(fmt.Sprintf("liquid: nil OperatorFunc for %q", name))
+	}
+
+	return func(a, b Expression) (bool, error) {
+		return fn(expressionToInterface(a), expressionToInterface(b))
+	}
+}
+
+// buildOperatorRegexp generates a regex matching all of the known operators,
+// longest first so that `<=` is preferred over `<`. Operators ending in a word
+// character must be followed by a word boundary, so `contains` doesn't match
+// the start of `containsAll`
+func buildOperatorRegexp(operators map[string]operator) *regexp.Regexp {
+	names := make([]string, 0, len(operators))
+	for name := range operators {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
 		}
-		return equal(a, b)
-	},
-	"<=": func(a, b Expression) (bool, error) {
-		if l, err := lt(a, b); err != nil || l {
-			return l, err
+		return names[i] < names[j]
+	})
+
+	patterns := make([]string, len(names))
+	for i, name := range names {
+		patterns[i] = regexp.QuoteMeta(name)
+		if last, _ := utf8.DecodeLastRuneInString(name); last == '_' || unicode.IsLetter(last) || unicode.IsDigit(last) {
+			patterns[i] += `\b`
 		}
-		return equal(a, b)
-	},
-	"contains": contains,
+	}
+
+	return regexp.MustCompile(fmt.Sprintf(`^(?:%v)`, strings.Join(patterns, "|")))
 }
 
+// NewCondition creates a Condition comparing op1 and op2 with the named operator.
+// If the operator is empty the Condition just checks that op1 is truthy, and op2 is ignored
 func NewCondition(op1 Expression, operator string, op2 Expression) (*Condition, error) {
+	return newCondition(nil, op1, operator, op2)
+}
+
+// newCondition creates a Condition which can also use the engine's operators
+func newCondition(engine *Engine, op1 Expression, operator string, op2 Expression) (*Condition, error) {
 
-	if found, ok := operators[operator]; ok {
-		return &Condition{a: op1, operator: found, b: op2}, nil
+	if operator == "" {
+		return &Condition{a: op1}, nil
 	}
 
-	//       # If the operator is empty this means that the decision statement is just
-	//       # a single variable. We can just poll this variable from the context and
-	//       # return this as the result.
-	//       return context.evaluate(left) if op.nil?
+	if _, ok := engine.orDefault().findOperator(operator); ok {
+		return &Condition{a: op1, operator: operator, b: op2}, nil
+	}
 
 	//       left = context.evaluate(left)
 	//       right = context.evaluate(right)
@@ -182,7 +280,7 @@ func NewCondition(op1 Expression, operator string, op2 Expression) (*Condition,
 	//       end
 
 	//  raise(Liquid::ArgumentError.new("Unknown operator #{op}"))
-	return nil, ErrInvalidOperator(operator)
+	return nil, argumentError("Unknown operator %v", operator)
 }
 
 // module Liquid
@@ -1,7 +1,9 @@
 package liquid
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -95,30 +97,104 @@ func TestContainsWorksOnStrings(t *testing.T) {
 
 func TestInvalidComparisonOperator(t *testing.T) {
 	err := checkCondition(t, integerExpr(1), "~~", integerExpr(0), false)
-	if !reflect.DeepEqual(err, ErrInvalidOperator("~~")) {
-		t.Errorf("Bad error for operator, want: %v got: %v", ErrInvalidOperator("~~"), err)
+	if !reflect.DeepEqual(err, argumentError("Unknown operator ~~")) {
+		t.Errorf("Bad error for operator, want: %v got: %v", argumentError("Unknown operator ~~"), err)
 	}
 }
 
 func TestComparisonOfIntAndString(t *testing.T) {
-	err := checkCondition(t, stringExpr("1"), ">", integerExpr(0), false)
-	if !reflect.DeepEqual(err, ErrBadArgument{}) {
-		t.Errorf("wrong error type, want: ErrBadArgment, got: %v", err)
+	want := argumentError("comparison of String with 0 failed")
+
+	for _, operator := range []string{">", "<", ">=", "<="} {
+		err := checkCondition(t, stringExpr("1"), operator, integerExpr(0), false)
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("wrong error for %v, want: %v, got: %v", operator, want, err)
+		}
+	}
+
+	err := checkCondition(t, integerExpr(1), "<", stringExpr("0"), false)
+	if want := (argumentError("comparison of Integer with String failed")); !reflect.DeepEqual(err, want) {
+		t.Errorf("wrong error, want: %v, got: %v", want, err)
+	}
+
+	err = checkCondition(t, stringExpr("1"), "<", floatExpr(1.5), false)
+	if want := (argumentError("comparison of String with 1.5 failed")); !reflect.DeepEqual(err, want) {
+		t.Errorf("wrong error, want: %v, got: %v", want, err)
+	}
+}
+
+func TestComparisonOfIntAndStringEquality(t *testing.T) {
+	if err := checkCondition(t, stringExpr("1"), "==", integerExpr(1), false); err != nil {
+		t.Error(err)
+	}
+	if err := checkCondition(t, stringExpr("1"), "!=", integerExpr(1), true); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNumericComparisonAcrossTypes(t *testing.T) {
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{
+				"json_price": float64(10),
+				"db_count":   int64(3),
+				"small":      int8(-1),
+				"unsigned":   uint32(7),
+				"float32":    float32(2.5),
+			},
+		},
 	}
 
-	err = checkCondition(t, stringExpr("1"), "<", integerExpr(0), false)
-	if !reflect.DeepEqual(err, ErrBadArgument{}) {
-		t.Errorf("wrong error type, want: ErrBadArgment, got: %v", err)
+	tests := []struct {
+		a        Expression
+		operator string
+		b        Expression
+		want     bool
+	}{
+		{integerExpr(1), "==", floatExpr(1.0), true},
+		{floatExpr(1.0), "==", integerExpr(1), true},
+		{integerExpr(1), "!=", floatExpr(1.5), true},
+		{integerExpr(1), "<", floatExpr(1.5), true},
+		{floatExpr(1.5), ">", integerExpr(1), true},
+		{floatExpr(2.0), "<=", integerExpr(2), true},
+		{floatExpr(2.0), ">=", integerExpr(3), false},
+		{ParseVariableLookup("json_price"), "==", integerExpr(10), true},
+		{ParseVariableLookup("json_price"), ">", integerExpr(9), true},
+		{ParseVariableLookup("db_count"), "==", integerExpr(3), true},
+		{ParseVariableLookup("db_count"), "<", floatExpr(3.5), true},
+		{ParseVariableLookup("db_count"), "==", ParseVariableLookup("db_count"), true},
+		{ParseVariableLookup("small"), "<", integerExpr(0), true},
+		{ParseVariableLookup("unsigned"), ">=", integerExpr(7), true},
+		{ParseVariableLookup("float32"), "==", floatExpr(2.5), true},
+		{arrayExpr{1, 2}, "==", arrayExpr{1.0, int64(2)}, true},
+		{arrayExpr{1, 2}, "contains", floatExpr(2), true},
+	}
+
+	for _, test := range tests {
+		if err := checkConditionContext(t, test.a, test.operator, test.b, test.want, ctx); err != nil {
+			t.Errorf("%v %v %v failed with %v", test.a.Name(), test.operator, test.b.Name(), err)
+		}
 	}
+}
 
-	err = checkCondition(t, stringExpr("1"), ">=", integerExpr(0), false)
-	if !reflect.DeepEqual(err, ErrBadArgument{}) {
-		t.Errorf("wrong error type, want: ErrBadArgment, got: %v", err)
+func TestNilComparisonsAreFalse(t *testing.T) {
+	for _, operator := range []string{"<", ">", "<=", ">="} {
+		if err := checkCondition(t, Nil, operator, integerExpr(1), false); err != nil {
+			t.Errorf("nil %v 1 should be false, got error: %v", operator, err)
+		}
+		if err := checkCondition(t, integerExpr(1), operator, Nil, false); err != nil {
+			t.Errorf("1 %v nil should be false, got error: %v", operator, err)
+		}
+		if err := checkCondition(t, ParseVariableLookup("not_assigned"), operator, stringExpr("a"), false); err != nil {
+			t.Errorf("not_assigned %v 'a' should be false, got error: %v", operator, err)
+		}
 	}
 
-	err = checkCondition(t, stringExpr("1"), "<=", integerExpr(0), false)
-	if !reflect.DeepEqual(err, ErrBadArgument{}) {
-		t.Errorf("wrong error type, want: ErrBadArgment, got: %v", err)
+	if err := checkCondition(t, Nil, "==", Nil, true); err != nil {
+		t.Error(err)
+	}
+	if err := checkCondition(t, Nil, "==", integerExpr(0), false); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -273,16 +349,57 @@ func TestAndCondition(t *testing.T) {
 
 }
 
+// unregisterOperator removes an operator added by a test
+func unregisterOperator(name string) {
+	defaultEngine.mu.Lock()
+	defer defaultEngine.mu.Unlock()
+	delete(defaultEngine.operators, name)
+	defaultEngine.operatorRegexp = buildOperatorRegexp(defaultEngine.operators)
+}
+
 func TestShouldAllowCustomProcOperator(t *testing.T) {
-	t.Skip("unimplemented")
-	//   def test_should_allow_custom_proc_operator
-	//     Condition.operators['starts_with'] = proc { |cond, left, right| left =~ %r{^#{right}} }
+	RegisterOperator("starts_with", func(left, right interface{}) (bool, error) {
+		return regexp.MustCompile("^" + fmt.Sprint(right)).MatchString(fmt.Sprint(left)), nil
+	})
+	defer unregisterOperator("starts_with")
 
-	//     assert_evalutes_true 'bob', 'starts_with', 'b'
-	//     assert_evalutes_false 'bob', 'starts_with', 'o'
-	//   ensure
-	//     Condition.operators.delete 'starts_with'
-	//   end
+	checkStringCondition(t, "bob", "starts_with", "b", true)
+	checkStringCondition(t, "bob", "starts_with", "o", false)
+}
+
+func TestCustomOperatorReceivesGoValues(t *testing.T) {
+	var gotLeft, gotRight interface{}
+	RegisterOperator("inspects", func(left, right interface{}) (bool, error) {
+		gotLeft, gotRight = left, right
+		return true, nil
+	})
+	defer unregisterOperator("inspects")
+
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{"tags": []interface{}{"sale"}},
+		},
+	}
+
+	if err := checkConditionContext(t, ParseVariableLookup("tags"), "inspects", integerExpr(1), true, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotLeft, []interface{}{"sale"}) || gotRight != 1 {
+		t.Errorf("operator got the wrong operands: %#v, %#v", gotLeft, gotRight)
+	}
+}
+
+func TestRegisterOperatorRejectsBadNames(t *testing.T) {
+	for _, name := range []string{"", "has key"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering operator %q should have panicked", name)
+				}
+			}()
+			RegisterOperator(name, func(left, right interface{}) (bool, error) { return false, nil })
+		}()
+	}
 }
 
 func TestLeftOrRightMayContainOperators(t *testing.T) {
@@ -294,3 +411,152 @@ func TestLeftOrRightMayContainOperators(t *testing.T) {
 	//     assert_evalutes_true VariableLookup.new("one"), '==', VariableLookup.new("another")
 	//   end
 }
+
+type emptinessDrop struct {
+	empty bool
+}
+
+func (d emptinessDrop) InvokeDrop(key string) interface{} {
+	if key == "empty?" {
+		return d.empty
+	}
+	return nil
+}
+
+func TestEmptyLiteral(t *testing.T) {
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{
+				"empty_string": "",
+				"string":       "hello",
+				"blank_string": "   ",
+				"empty_array":  []interface{}{},
+				"array":        []interface{}{1},
+				"empty_hash":   map[string]interface{}{},
+				"hash":         map[string]interface{}{"a": 1},
+				"nil":          nil,
+				"empty_drop":   emptinessDrop{true},
+				"drop":         emptinessDrop{false},
+			},
+		},
+	}
+
+	tests := []struct {
+		variable string
+		want     bool
+	}{
+		{"empty_string", true},
+		{"string", false},
+		{"blank_string", false},
+		{"empty_array", true},
+		{"array", false},
+		{"empty_hash", true},
+		{"hash", false},
+		{"nil", false},
+		{"not_assigned", false},
+		{"empty_drop", true},
+		{"drop", false},
+	}
+
+	for _, test := range tests {
+		lookup := ParseVariableLookup(test.variable)
+		if err := checkConditionContext(t, lookup, "==", ParseExpression("empty"), test.want, ctx); err != nil {
+			t.Errorf("%v == empty failed with %v", test.variable, err)
+		}
+		if err := checkConditionContext(t, ParseExpression("empty"), "==", lookup, test.want, ctx); err != nil {
+			t.Errorf("empty == %v failed with %v", test.variable, err)
+		}
+		if err := checkConditionContext(t, lookup, "!=", ParseExpression("empty"), !test.want, ctx); err != nil {
+			t.Errorf("%v != empty failed with %v", test.variable, err)
+		}
+	}
+}
+
+func TestBlankLiteral(t *testing.T) {
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{
+				"empty_string": "",
+				"string":       "hello",
+				"blank_string": " \n\t ",
+				"empty_array":  []interface{}{},
+				"array":        []interface{}{1},
+				"empty_hash":   map[string]interface{}{},
+				"nil":          nil,
+				"false":        false,
+				"true":         true,
+				"number":       0,
+				"drop":         emptinessDrop{true},
+			},
+		},
+	}
+
+	tests := []struct {
+		variable string
+		want     bool
+	}{
+		{"empty_string", true},
+		{"string", false},
+		{"blank_string", true},
+		{"empty_array", true},
+		{"array", false},
+		{"empty_hash", true},
+		{"nil", true},
+		{"not_assigned", true},
+		{"false", true},
+		{"true", false},
+		{"number", false},
+		// drops must respond to blank? themselves
+		{"drop", false},
+	}
+
+	for _, test := range tests {
+		lookup := ParseVariableLookup(test.variable)
+		if err := checkConditionContext(t, lookup, "==", ParseExpression("blank"), test.want, ctx); err != nil {
+			t.Errorf("%v == blank failed with %v", test.variable, err)
+		}
+		if err := checkConditionContext(t, lookup, "!=", ParseExpression("blank"), !test.want, ctx); err != nil {
+			t.Errorf("%v != blank failed with %v", test.variable, err)
+		}
+	}
+}
+
+func TestMethodLiteralsAreNotEqualToThemselves(t *testing.T) {
+	if err := checkCondition(t, Empty, "==", Empty, false); err != nil {
+		t.Error(err)
+	}
+	if err := checkCondition(t, Blank, "==", Blank, false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConditionWithoutOperator(t *testing.T) {
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{"customer": map[string]interface{}{"name": "Tobi"}, "zero": 0},
+		},
+	}
+
+	tests := []struct {
+		expr Expression
+		want bool
+	}{
+		{True, true},
+		{False, false},
+		{Nil, false},
+		{integerExpr(0), true},
+		{stringExpr(""), true},
+		{arrayExpr{}, true},
+		{ParseVariableLookup("customer"), true},
+		{ParseVariableLookup("customer.name"), true},
+		{ParseVariableLookup("zero"), true},
+		{ParseVariableLookup("customer.email"), false},
+		{ParseVariableLookup("not_assigned"), false},
+	}
+
+	for _, test := range tests {
+		if err := checkConditionContext(t, test.expr, "", nil, test.want, ctx); err != nil {
+			t.Errorf("%v failed with %v", test.expr.Name(), err)
+		}
+	}
+}
@@ -1,29 +1,114 @@
 package liquid
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf8"
 )
 
 var (
-	ErrNoScope     = errors.New(`no scopes to pop`)
-	ErrVarNotFound = errors.New(`variable not found`)
+	ErrNoScope     = ContextError{Detail{Message: "no scopes to pop"}}
+	ErrVarNotFound = ContextError{Detail{Message: "variable not found"}}
 )
 
 type Context struct {
-	scopes scopeStack
+	scopes   scopeStack
+	options  options
+	template *Template
+	engine   *Engine
+	goctx    context.Context
+
+	resources resourceUsage
+	registers map[string]interface{}
+	// depth is how deeply the nodes being rendered are nested
+	depth int
+
+	// errors are collected with the CollectErrors policy, aborted is the
+	// error that stopped the render
+	errors  RenderErrors
+	aborted error
 }
 
 func newContext() Context {
 	s := scopeStack{}
-	return Context{s}
+	return Context{scopes: s, goctx: context.Background(), registers: map[string]interface{}{}}
+}
+
+// Context returns the context.Context of the render, which tags, filters and
+// Drops that do I/O should use so that their work stops when the render is
+// cancelled. See Template.RenderContext
+func (c *Context) Context() context.Context {
+	if c.goctx == nil {
+		return context.Background()
+	}
+	return c.goctx
+}
+
+// Registers hold the state of tags and filters for the rest of the render, such
+// as the position of a cycle. They start out empty for every render, so nothing
+// is shared between renders of the same Template, and aren't visible to templates
+func (c *Context) Registers() map[string]interface{} {
+	return c.registers
 }
 
+// FileSystem returns where the render loads partial templates from, a
+// BlankFileSystem unless one was given with WithFileSystem
+func (c *Context) FileSystem() FileSystem {
+	if c.options.fileSystem == nil {
+		return BlankFileSystem{}
+	}
+	return c.options.fileSystem
+}
+
+// errorAt gives an error raised while rendering a node the node's position,
+// unless it already has the position of a node nested in it
+func (c *Context) errorAt(err error, node Node) error {
+	var located Error
+	n, ok := node.(positioned)
+	if !ok || c.template == nil || errors.As(err, &located) {
+		return err
+	}
+	return c.template.errorAt(err, n.Position())
+}
+
+// handleError applies the error handler, or the ErrorPolicy, to an error raised
+// by a node. It returns the output to render instead of the node, or the error
+// that aborts the render
+func (c *Context) handleError(err error) (string, error) {
+	var output string
+	switch {
+	case c.options.errorHandler != nil:
+		output, err = c.options.errorHandler(err)
+	case c.options.errorPolicy == RenderErrorsInline:
+		output, err = inlineError(err), nil
+	case c.options.errorPolicy == CollectErrors:
+		c.errors = append(c.errors, err)
+		err = nil
+	}
+
+	c.aborted = err
+	return output, err
+}
+
+// abort stops the render with an error, regardless of the ErrorPolicy
+func (c *Context) abort(err error) error {
+	c.aborted = err
+	return err
+}
+
+// Assign sets a variable in the current scope, the value counts towards the
+// render's AssignScore limit
 func (c *Context) Assign(k string, v interface{}) error {
 	scope, err := c.scopes.curr()
 	if err != nil {
 		return err
 	}
+	if err := c.resources.assign(v, c.options.limits); err != nil {
+		return err
+	}
 	scope[k] = v
 	return nil
 }
@@ -41,8 +126,16 @@ func (c *Context) Get(k string) (interface{}, error) {
 	return nil, ErrVarNotFound
 }
 
+// interfaceToExpression wraps a Go value in the matching Expression type. Go values
+// that are nil (typed nil pointers, nil slices, nil maps etc.) are all treated as nil
 func interfaceToExpression(v interface{}) Expression {
+	if isNil(v) {
+		return Nil
+	}
+
 	switch v.(type) {
+	case bool:
+		return boolExpr(v.(bool))
 	case string:
 		return stringExpr(v.(string))
 	case int:
@@ -51,8 +144,99 @@ func interfaceToExpression(v interface{}) Expression {
 		return floatExpr(v.(float64))
 	case []interface{}:
 		return arrayExpr(v.([]interface{}))
+	case map[string]interface{}:
+		return hashExpr(v.(map[string]interface{}))
+	case Vars:
+		return hashExpr(v.(Vars))
+	case Drop:
+		return dropExpr{v.(Drop)}
 	}
-	panic(fmt.Sprintf("DONT UNDERSTAND %v", v))
+
+	// Normalize the other numeric types (int64 from a database, named types etc.)
+	// so that they compare correctly with template literals, and copy typed slices
+	// and maps so that they can be used like any other array or hash
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return integerExpr(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return floatExpr(float64(value.Uint()))
+		}
+		return integerExpr(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return floatExpr(value.Float())
+	case reflect.String:
+		return stringExpr(value.String())
+	case reflect.Bool:
+		return boolExpr(value.Bool())
+	case reflect.Slice, reflect.Array:
+		array := make(arrayExpr, value.Len())
+		for i := range array {
+			array[i] = value.Index(i).Interface()
+		}
+		return array
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			break
+		}
+		hash := make(hashExpr, value.Len())
+		for _, key := range value.MapKeys() {
+			hash[key.String()] = value.MapIndex(key).Interface()
+		}
+		return hash
+	}
+
+	return objectExpr{v}
+}
+
+// expressionToInterface is the inverse of interfaceToExpression, unwrapping
+// an evaluated expression into a plain Go value
+func expressionToInterface(e Expression) interface{} {
+	switch e.(type) {
+	case nil, nilExpr:
+		return nil
+	case boolExpr:
+		return bool(e.(boolExpr))
+	case stringExpr:
+		return string(e.(stringExpr))
+	case literalExpr:
+		return string(e.(literalExpr))
+	case integerExpr:
+		return int(e.(integerExpr))
+	case floatExpr:
+		return float64(e.(floatExpr))
+	case arrayExpr:
+		return []interface{}(e.(arrayExpr))
+	case hashExpr:
+		return map[string]interface{}(e.(hashExpr))
+	case rangeExpr:
+		r := e.(rangeExpr)
+		var values []interface{}
+		for i := r.start; i <= r.end; i++ {
+			values = append(values, i)
+		}
+		return values
+	case dropExpr:
+		return e.(dropExpr).drop
+	case objectExpr:
+		return e.(objectExpr).value
+	case methodLiteralExpr:
+		return ""
+	}
+	return e
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return value.IsNil()
+	}
+	return false
 }
 
 func (c *Context) FindVariable(e Expression) (Expression, error) {
@@ -64,13 +248,13 @@ func (c *Context) FindVariable(e Expression) (Expression, error) {
 	case literalExpr:
 		key = string(e.(literalExpr))
 	default:
-		return nil, fmt.Errorf("DUNNO WHAT TO DO WITH %v OMG", e)
+		return nil, ContextError{Detail{Message: fmt.Sprintf("can't find a variable named by %v", e.Name())}}
 	}
 
 	value, err := c.Get(key)
 	if err != nil {
 		if err == ErrVarNotFound {
-			return nil, ErrNotFound(key)
+			return nil, UndefinedVariable{Name: key}
 		}
 		return nil, err
 	}
@@ -78,7 +262,78 @@ func (c *Context) FindVariable(e Expression) (Expression, error) {
 	return interfaceToExpression(value), nil
 }
 
-func (c *Context) lookupAndEvaluate() {
+// lookupAndEvaluate fetches a key from a hash, array, drop or, as far as the
+// ReflectionPolicy allows, another Go value. The second return value reports
+// whether the object was able to look up the key at all
+func (c *Context) lookupAndEvaluate(object, key Expression) (Expression, bool, error) {
+	switch object.(type) {
+	case hashExpr:
+		k, ok := key.(stringExpr)
+		if l, isLiteral := key.(literalExpr); isLiteral {
+			k, ok = stringExpr(l), true
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		value, ok := object.(hashExpr)[string(k)]
+		if !ok {
+			return nil, false, nil
+		}
+		return interfaceToExpression(value), true, nil
+	case arrayExpr:
+		index, ok := key.(integerExpr)
+		if !ok {
+			return nil, false, nil
+		}
+		array := object.(arrayExpr)
+		// ruby arrays support negative indices
+		if index < 0 {
+			index += integerExpr(len(array))
+		}
+		if index < 0 || int(index) >= len(array) {
+			return Nil, true, nil
+		}
+		return interfaceToExpression(array[index]), true, nil
+	case dropExpr:
+		return interfaceToExpression(invokeDrop(c.Context(), object.(dropExpr).drop, key.Name())), true, nil
+	case objectExpr:
+		switch key.(type) {
+		case stringExpr, literalExpr:
+			return c.options.reflection.lookup(c, object.(objectExpr).value, key.Name())
+		}
+	}
+	return nil, false, nil
+}
+
+// invokeCommand calls one of the commandMethods (size, first, last) on an object
+func invokeCommand(object Expression, command string) (Expression, bool) {
+	switch object.(type) {
+	case arrayExpr:
+		array := object.(arrayExpr)
+		switch command {
+		case "size":
+			return integerExpr(len(array)), true
+		case "first":
+			if len(array) == 0 {
+				return Nil, true
+			}
+			return interfaceToExpression(array[0]), true
+		case "last":
+			if len(array) == 0 {
+				return Nil, true
+			}
+			return interfaceToExpression(array[len(array)-1]), true
+		}
+	case hashExpr:
+		if command == "size" {
+			return integerExpr(len(object.(hashExpr))), true
+		}
+	case stringExpr:
+		if command == "size" {
+			return integerExpr(utf8.RuneCountInString(string(object.(stringExpr)))), true
+		}
+	}
+	return nil, false
 }
 
 type scopeStack []Vars
@@ -1,6 +1,9 @@
 package liquid
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 // integration/document_test.rb
 
@@ -10,11 +13,12 @@ func TestUnexpectedOuterTag(t *testing.T) {
 		t.Error("Expected syntax error parsing template")
 		return
 	}
-	if _, ok := err.(ErrSyntax); !ok {
+	var syntaxErr SyntaxError
+	if !errors.As(err, &syntaxErr) {
 		t.Errorf("Expected syntax error, got: %v", err)
 	}
 
-	if err.Error() != "Liquid syntax error: Unexpected outer 'else' tag" {
+	if err.Error() != "line 1, column 1: Liquid syntax error: Unexpected outer 'else' tag" {
 		t.Errorf("Wrong error text, got: %v", err)
 	}
 }
@@ -25,11 +29,12 @@ func TestUnknownTag(t *testing.T) {
 		t.Error("Expected syntax error parsing template")
 		return
 	}
-	if _, ok := err.(ErrSyntax); !ok {
+	var syntaxErr SyntaxError
+	if !errors.As(err, &syntaxErr) {
 		t.Errorf("Expected syntax error, got: %v", err)
 	}
 
-	if err.Error() != "Liquid syntax error: Unknown tag 'foo'" {
+	if err.Error() != "line 1, column 1: Liquid syntax error: Unknown tag 'foo'" {
 		t.Errorf("Wrong error text, got: %v", err)
 	}
 }
@@ -0,0 +1,36 @@
+package liquid
+
+import "context"
+
+// Drop is implemented by Go values that expose a controlled set of keys and
+// methods to templates, rather than having their contents looked up directly.
+// It is the analog to Liquid::Drop
+type Drop interface {
+	// InvokeDrop returns the value for the supplied key or method name,
+	// or nil if the drop doesn't respond to it
+	InvokeDrop(key string) interface{}
+}
+
+// IterableDrop is implemented by Drops that represent a collection, allowing
+// them to be used with the array filters
+type IterableDrop interface {
+	Drop
+	// Items returns the contents of the collection
+	Items() []interface{}
+}
+
+// ContextDrop is implemented by Drops that do I/O to look up their keys. They're
+// given the context.Context of the render, so the work stops when it's cancelled
+type ContextDrop interface {
+	Drop
+	// InvokeDropContext is called instead of InvokeDrop
+	InvokeDropContext(ctx context.Context, key string) interface{}
+}
+
+// invokeDrop looks up a key of a drop, with the context.Context if it's a ContextDrop
+func invokeDrop(ctx context.Context, drop Drop, key string) interface{} {
+	if d, ok := drop.(ContextDrop); ok {
+		return d.InvokeDropContext(ctx, key)
+	}
+	return drop.InvokeDrop(key)
+}
@@ -0,0 +1,151 @@
+package liquid
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Engine holds extensions which are only available to the templates it parses,
+// so that different sets of templates can have their own tags, filters and
+// operators. Extensions can be registered while the engine's templates are
+// being parsed and rendered. The package-level ParseTemplate, RegisterTag,
+// RegisterFilter and RegisterOperator use a default engine, which is isolated
+// from the others like any other engine
+type Engine struct {
+	mu             sync.RWMutex
+	tags           map[string]Tag
+	filters        map[string]contextFilterFunc
+	operators      map[string]operator
+	operatorRegexp *regexp.Regexp
+	options        []Option
+}
+
+// defaultEngine is the engine used by the package-level functions
+var defaultEngine = NewEngine()
+
+// NewEngine creates an Engine with no extensions, its templates can only use the
+// standard tags, filters and operators until some are registered on it, even if
+// they have been registered with the package-level functions. The options are defaults
+// for every parse and render of the engine's templates, such as WithTimeZone
+// or WithFileSystem, which the options passed to Render can override
+func NewEngine(opts ...Option) *Engine {
+	return &Engine{
+		tags:      map[string]Tag{},
+		filters:   map[string]contextFilterFunc{},
+		operators: map[string]operator{},
+		options:   opts,
+	}
+}
+
+// ParseTemplate parses a template which uses the engine's extensions and options
+func (e *Engine) ParseTemplate(template string, opts ...Option) (*Template, error) {
+	return parseTemplate(template, e, e.withOptions(opts))
+}
+
+// Render parses and renders a template in one go, the options apply to both
+func (e *Engine) Render(template string, vars Vars, opts ...Option) (string, error) {
+	t, err := e.ParseTemplate(template, opts...)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(vars, opts...)
+}
+
+// orDefault returns the engine, or the default engine for templates and contexts
+// which weren't created by one
+func (e *Engine) orDefault() *Engine {
+	if e == nil {
+		return defaultEngine
+	}
+	return e
+}
+
+// withOptions prepends the engine's default options, so that opts can override them
+func (e *Engine) withOptions(opts []Option) []Option {
+	return append(e.options[:len(e.options):len(e.options)], opts...)
+}
+
+// RegisterTag makes a tag available to the engine's templates. Tags registered
+// on an engine take precedence over the standard ones
+func (e *Engine) RegisterTag(name string, tag Tag) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tags[name] = tag
+}
+
+// findTag looks up a tag by name, preferring the engine's tags to the standard ones
+func (e *Engine) findTag(name string) (Tag, bool) {
+	e.mu.RLock()
+	tag, ok := e.tags[name]
+	e.mu.RUnlock()
+	if ok {
+		return tag, true
+	}
+
+	tag, ok = standardTags[name]
+	return tag, ok
+}
+
+// RegisterFilter makes a filter available to the engine's templates, see the
+// package-level RegisterFilter for the functions that can be used. Filters
+// registered on an engine take precedence over the standard filters
+func (e *Engine) RegisterFilter(name string, fn interface{}) {
+	filter := mustReflectFilter(name, fn)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.filters[name] = filter
+}
+
+// findFilter looks up a filter by name, preferring the engine's filters to the standard ones
+func (e *Engine) findFilter(name string) (contextFilterFunc, bool) {
+	e.mu.RLock()
+	fn, ok := e.filters[name]
+	e.mu.RUnlock()
+	if ok {
+		return fn, true
+	}
+
+	return lookupFilter(name)
+}
+
+// RegisterOperator makes an operator available to the engine's templates, see
+// the package-level RegisterOperator. Operators registered on an engine take
+// precedence over the standard ones
+func (e *Engine) RegisterOperator(name string, fn OperatorFunc) {
+	op := mustOperator(name, fn)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.operators[name] = op
+	e.operatorRegexp = buildOperatorRegexp(e.operators)
+}
+
+// findOperator looks up an operator by name, preferring the engine's operators
+// to the standard ones
+func (e *Engine) findOperator(name string) (operator, bool) {
+	e.mu.RLock()
+	op, ok := e.operators[name]
+	e.mu.RUnlock()
+	if ok {
+		return op, true
+	}
+
+	op, ok = standardOperators[name]
+	return op, ok
+}
+
+// matchOperator returns the comparison operator at the start of s, if there is
+// one. The longest of the engine's and the standard operators wins
+func (e *Engine) matchOperator(s string) string {
+	match := standardOperatorRegexp.FindString(s)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.operatorRegexp != nil {
+		if own := e.operatorRegexp.FindString(s); len(own) >= len(match) {
+			return own
+		}
+	}
+	return match
+}
@@ -0,0 +1,240 @@
+package liquid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// partialTag renders a partial template from the render's FileSystem
+type partialTag struct{}
+
+func (t partialTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
+	return partialNode(strings.Trim(strings.TrimSpace(tagMarkup(markup)), `'"`)), nil
+}
+
+type partialNode string
+
+func (n partialNode) Render(ctx *Context) (string, error) {
+	source, err := ctx.FileSystem().ReadTemplateFile(string(n))
+	if err != nil {
+		return "", err
+	}
+	return ctx.engine.Render(source, nil)
+}
+
+func (n partialNode) Blank() bool { return false }
+
+func TestEngineTags(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterTag("skip", &commentTag{})
+	other := NewEngine()
+
+	if got, err := engine.Render(`a{% skip %}b{% endskip %}c`, nil); err != nil || got != "ac" {
+		t.Errorf("want: %q, got: %q (%v)", "ac", got, err)
+	}
+	for _, parse := range []func(string, ...Option) (*Template, error){other.ParseTemplate, ParseTemplate} {
+		if _, err := parse(`{% skip %}{% endskip %}`); !errors.Is(err, SyntaxError{Detail{Message: "Unknown tag 'skip'"}}) {
+			t.Errorf("tag leaked out of its engine, got: %v", err)
+		}
+	}
+
+	// engine tags take precedence over the standard ones
+	engine.RegisterTag("if", &commentTag{})
+	if got, err := engine.Render(`a{% if true %}b{% endif %}`, nil); err != nil || got != "a" {
+		t.Errorf("want: %q, got: %q (%v)", "a", got, err)
+	}
+	if got, err := other.Render(`a{% if true %}b{% endif %}`, nil); err != nil || got != "ab" {
+		t.Errorf("want: %q, got: %q (%v)", "ab", got, err)
+	}
+
+	// blocks are rendered by the tag which parsed them
+	tpl, err := other.ParseTemplate(`a{% comment %}b{% endcomment %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.RegisterTag("comment", &ifTag{})
+	if got, err := tpl.Render(nil); err != nil || got != "a" {
+		t.Errorf("want: %q, got: %q (%v)", "a", got, err)
+	}
+}
+
+// unregisterTag removes a tag added to the default engine by a test
+func unregisterTag(name string) {
+	defaultEngine.mu.Lock()
+	defer defaultEngine.mu.Unlock()
+	delete(defaultEngine.tags, name)
+}
+
+func TestDefaultEngine(t *testing.T) {
+	RegisterTag("skip", &commentTag{})
+	defer unregisterTag("skip")
+	RegisterFilter("shout", func(input string) string { return strings.ToUpper(input) })
+	defer unregisterFilter("shout")
+	RegisterOperator("startswith", func(left, right interface{}) (bool, error) {
+		l, _ := left.(string)
+		r, _ := right.(string)
+		return strings.HasPrefix(l, r), nil
+	})
+	defer unregisterOperator("startswith")
+
+	// the package-level functions share the default engine
+	source := `{% skip %}x{% endskip %}{% if 'abc' startswith 'a' %}{{ 'a' | shout }}{% endif %}`
+	tpl, err := ParseTemplate(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(nil); err != nil || got != "A" {
+		t.Errorf("want: %q, got: %q (%v)", "A", got, err)
+	}
+
+	// which other engines don't see
+	engine := NewEngine()
+	if _, err := engine.ParseTemplate(`{% skip %}{% endskip %}`); !errors.Is(err, SyntaxError{Detail{Message: "Unknown tag 'skip'"}}) {
+		t.Errorf("tag leaked out of the default engine, got: %v", err)
+	}
+	if _, err := engine.ParseTemplate(`{% if 'abc' startswith 'a' %}{% endif %}`); !errors.Is(err, SyntaxError{}) {
+		t.Errorf("operator leaked out of the default engine, got: %v", err)
+	}
+	if got, err := engine.Render(`{{ 'a' | shout }}`, nil); err != nil || got != "a" {
+		t.Errorf("filter leaked out of the default engine, got: %q (%v)", got, err)
+	}
+}
+
+func TestEngineOperators(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterOperator("startswith", func(left, right interface{}) (bool, error) {
+		l, _ := left.(string)
+		r, _ := right.(string)
+		return strings.HasPrefix(l, r), nil
+	})
+	other := NewEngine()
+
+	source := `{% if title startswith 'Sale' %}yes{% else %}no{% endif %}`
+	if got, err := engine.Render(source, Vars{"title": "Sale on now"}); err != nil || got != "yes" {
+		t.Errorf("want: %q, got: %q (%v)", "yes", got, err)
+	}
+	if _, err := other.ParseTemplate(source); !errors.Is(err, SyntaxError{}) {
+		t.Errorf("operator leaked out of its engine, got: %v", err)
+	}
+
+	// the longest operator wins, whichever registry it's from
+	engine.RegisterOperator("=", func(left, right interface{}) (bool, error) { return false, nil })
+	if got, err := engine.Render(`{% if 1 == 1 %}yes{% endif %}`, nil); err != nil || got != "yes" {
+		t.Errorf("want: %q, got: %q (%v)", "yes", got, err)
+	}
+}
+
+func TestEngineFileSystem(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "shop"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range map[string]string{"_product.liquid": "Product", "shop/_cart.liquid": "Cart"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := NewEngine(WithFileSystem(LocalFileSystem{Root: root}))
+	engine.RegisterTag("partial", partialTag{})
+
+	tests := []struct {
+		source string
+		want   string
+		err    string
+	}{
+		{`{% partial 'product' %}`, "Product", ""},
+		{`{% partial 'shop/cart' %}`, "Cart", ""},
+		{`{% partial 'missing' %}`, "", "No such template 'missing'"},
+		{`{% partial '../product' %}`, "", "Illegal template name '../product'"},
+	}
+	for _, test := range tests {
+		got, err := engine.Render(test.source, nil)
+		if test.err != "" {
+			if !errors.Is(err, FileSystemError{Detail{Message: test.err}}) {
+				t.Errorf("%v: want %q, got: %v", test.source, test.err, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v: want: %q, got: %q (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	// without a file system partials can't be loaded
+	other := NewEngine()
+	other.RegisterTag("partial", partialTag{})
+	if _, err := other.Render(`{% partial 'product' %}`, nil); !errors.Is(err, FileSystemError{}) {
+		t.Errorf("want a FileSystemError, got: %v", err)
+	}
+}
+
+func TestEngineConcurrentRegistration(t *testing.T) {
+	engine := NewEngine()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("f%v", i)
+			engine.RegisterFilter(name, func(input string) string { return input })
+			engine.RegisterTag(name, &commentTag{})
+			engine.RegisterOperator(name, func(left, right interface{}) (bool, error) { return true, nil })
+
+			source := fmt.Sprintf(`{%% %v %%}{%% end%v %%}{%% if 1 %v 2 %%}{{ 'x' | %v }}{%% endif %%}`, name, name, name, name)
+			if got, err := engine.Render(source, nil); err != nil || got != "x" {
+				t.Errorf("want: %q, got: %q (%v)", "x", got, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEngineSandbox(t *testing.T) {
+	tests := []struct {
+		opts   []Option
+		source string
+		err    string
+	}{
+		{[]Option{WithAllowedTags("if")}, `{% if true %}{% endif %}{% unless false %}{% endunless %}`, "Tag 'unless' is not allowed"},
+		{[]Option{WithDeniedTags("comment")}, `{% if true %}{% comment %}{% endcomment %}{% endif %}`, "Tag 'comment' is not allowed"},
+		{[]Option{WithAllowedTags("if", "unless"), WithDeniedTags("unless")}, `{% unless x %}{% endunless %}`, "Tag 'unless' is not allowed"},
+		{[]Option{WithAllowedFilters("upcase")}, `{{ 'a' | upcase | downcase }}`, "Filter 'downcase' is not allowed"},
+		{[]Option{WithDeniedFilters("date")}, `{% if true %}{{ 'now' | date: '%Y' }}{% endif %}`, "Filter 'date' is not allowed"},
+		{[]Option{WithTagCheck(func(name, markup string) error {
+			if name == "partial" && markup != "'footer'" {
+				return fmt.Errorf("only the footer can be included, not %v", markup)
+			}
+			return nil
+		})}, `{% partial 'footer' %}{% partial 'secrets' %}`, "only the footer can be included, not 'secrets'"},
+	}
+
+	for _, test := range tests {
+		engine := NewEngine(test.opts...)
+		engine.RegisterTag("partial", partialTag{})
+		_, err := engine.ParseTemplate(test.source)
+		var syntaxErr SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.message() != test.err {
+			t.Errorf("%v: want %q, got: %v", test.source, test.err, err)
+		}
+
+		// other engines aren't restricted
+		other := NewEngine()
+		other.RegisterTag("partial", partialTag{})
+		if _, err := other.ParseTemplate(test.source); err != nil {
+			t.Errorf("%v: want the template to parse, got: %v", test.source, err)
+		}
+	}
+
+	// allowed tags and filters work as usual, and end and else tags don't need to be listed
+	engine := NewEngine(WithAllowedTags("if"), WithAllowedFilters("upcase"))
+	if got, err := engine.Render(`{% if x %}{{ x | upcase }}{% else %}none{% endif %}`, Vars{"x": "a"}); err != nil || got != "A" {
+		t.Errorf("want: %q, got: %q (%v)", "A", got, err)
+	}
+}
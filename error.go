@@ -1,26 +1,372 @@
 package liquid
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
-// ErrSyntax acts as a generic liquid syntax error
-type ErrSyntax string
+// errorKindRegexp splits messages such as `Liquid syntax error: ...` into the
+// kind of error and the rest of the message
+var errorKindRegexp = regexp.MustCompile(`(?s)\A(Liquid(?: \w+)* error): (.*)\z`)
 
-func (e ErrSyntax) Error() string {
-	return fmt.Sprintf("Liquid syntax error: %v", string(e))
+// Position is a (one based) line and column in the source of a template
+type Position struct {
+	Line   int
+	Column int
 }
 
-type liquidContext interface {
-	String() string
+func (p Position) String() string {
+	return fmt.Sprintf("line %v, column %v", p.Line, p.Column)
 }
 
-// LiquidError prettifies an error with a liquid Context
-func LiquidError(err string, context liquidContext) error {
-	return fmt.Errorf("%v - %v", err, context)
+// Error is an error raised by part of a template, either while parsing or rendering
+// it, along with the name of the template and the position of the part
+type Error struct {
+	// Template is the name given with WithTemplateName, if any
+	Template string
+	Position
+	Err error
+
+	// source is the line of the template at Position, for the excerpt
+	source string
+}
+
+func (e Error) Error() string {
+	if e.Template != "" {
+		return fmt.Sprintf("%v:%v:%v: %v", e.Template, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%v: %v", e.Position, e.Err)
+}
+
+// Unwrap returns the underlying error, such as a SyntaxError
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Excerpt shows the line of the template the error was raised on, with a caret
+// pointing at the column
+//
+//	3 | {{ product.price | money_with_curency }}
+//	  | ^
+func (e Error) Excerpt() string {
+	number := fmt.Sprint(e.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	// keep the tabs in front of the column, so the caret lines up with it
+	var indent bytes.Buffer
+	for i, r := range e.source {
+		if utf8.RuneCountInString(e.source[:i]) >= e.Column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	return fmt.Sprintf("%v | %v\n%v | %v^", number, e.source, gutter, indent.String())
+}
+
+// sourceLine returns a (one based) line of a template's source
+func sourceLine(source string, line int) string {
+	lines := strings.SplitN(source, "\n", line+1)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line-1], "\r")
+}
+
+// RenderErrors are the errors collected by a render with the CollectErrors policy
+type RenderErrors []error
+
+func (e RenderErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors, so errors.Is and errors.As look through all of them
+func (e RenderErrors) Unwrap() []error {
+	return e
+}
+
+// inlineError is the message rendered in place of a node with the RenderErrorsInline
+// policy, which adds the line to the message like Liquid::Error#to_s
+//
+//	Liquid error (line 3): divided by 0
+func inlineError(err error) string {
+	message := err.Error()
+	var located Error
+	if errors.As(err, &located) {
+		message = located.Err.Error()
+	}
+
+	kind := "Liquid error"
+	if matches := errorKindRegexp.FindStringSubmatch(message); matches != nil {
+		kind, message = matches[1], matches[2]
+	}
+	if located.Line > 0 {
+		return fmt.Sprintf("%v (line %v): %v", kind, located.Line, message)
+	}
+	return fmt.Sprintf("%v: %v", kind, message)
+}
+
+// Detail is embedded in each type of error raised by templates. The types group
+// errors by what went wrong, so they can be told apart with errors.As, or with
+// errors.Is and an empty error of the type
+//
+//	if errors.Is(err, liquid.SyntaxError{}) {
+//		// the template has to be fixed
+//	}
+type Detail struct {
+	Message string
+	// Position is where the error was raised in the template, once it's known
+	Position Position
+	// Err is the underlying Go error, if any
+	Err error
+}
+
+// Unwrap returns the underlying Go error
+func (d Detail) Unwrap() error {
+	return d.Err
+}
+
+func (d Detail) message() string {
+	if d.Message == "" && d.Err != nil {
+		return d.Err.Error()
+	}
+	return d.Message
+}
+
+// matches reports whether the detail is the target's, which matches any
+// detail if it has no message
+func (d Detail) matches(target Detail) bool {
+	return target.message() == "" || target.message() == d.message()
+}
+
+// locatable is implemented by the errors which record their position
+type locatable interface {
+	at(pos Position) error
+}
+
+// SyntaxError is the analog to Liquid::SyntaxError, raised while parsing markup
+// which isn't valid Liquid
+type SyntaxError struct{ Detail }
+
+func syntaxError(format string, a ...interface{}) SyntaxError {
+	return SyntaxError{Detail{Message: sprintf(format, a...)}}
+}
+
+func (e SyntaxError) Error() string {
+	return "Liquid syntax error: " + e.message()
+}
+
+// Is matches SyntaxErrors, with the same message if the target has one
+func (e SyntaxError) Is(target error) bool {
+	t, ok := target.(SyntaxError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e SyntaxError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// ArgumentError is the analog to Liquid::ArgumentError, raised for things like
+// filters given the wrong arguments, or comparing a string with a number
+type ArgumentError struct{ Detail }
+
+func argumentError(format string, a ...interface{}) ArgumentError {
+	return ArgumentError{Detail{Message: sprintf(format, a...)}}
+}
+
+func (e ArgumentError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches ArgumentErrors, with the same message if the target has one
+func (e ArgumentError) Is(target error) bool {
+	t, ok := target.(ArgumentError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e ArgumentError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// UndefinedVariable is the analog to Liquid::UndefinedVariable, raised in strict
+// variables mode when a variable or one of its keys doesn't exist. Name is the full
+// path of the lookup up to the missing part, such as product.vendor.name
+type UndefinedVariable struct {
+	Detail
+	Name string
+}
+
+func (e UndefinedVariable) Error() string {
+	return "Liquid error: undefined variable " + e.Name
+}
+
+// Is matches UndefinedVariables, with the same name if the target has one
+func (e UndefinedVariable) Is(target error) bool {
+	t, ok := target.(UndefinedVariable)
+	return ok && (t.Name == "" || t.Name == e.Name)
+}
+
+func (e UndefinedVariable) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// UndefinedFilter is the analog to Liquid::UndefinedFilter, raised in strict
+// filters mode when a template uses a filter which doesn't exist
+type UndefinedFilter struct {
+	Detail
+	Name string
+}
+
+func (e UndefinedFilter) Error() string {
+	return "Liquid error: undefined filter " + e.Name
+}
+
+// Is matches UndefinedFilters, with the same name if the target has one
+func (e UndefinedFilter) Is(target error) bool {
+	t, ok := target.(UndefinedFilter)
+	return ok && (t.Name == "" || t.Name == e.Name)
+}
+
+func (e UndefinedFilter) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// FileSystemError is the analog to Liquid::FileSystemError, raised when a
+// partial template can't be read
+type FileSystemError struct{ Detail }
+
+func (e FileSystemError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches FileSystemErrors, with the same message if the target has one
+func (e FileSystemError) Is(target error) bool {
+	t, ok := target.(FileSystemError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e FileSystemError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// ResourceLimitError is raised when a render goes over one of its resource limits
+type ResourceLimitError struct{ Detail }
+
+// MemoryError is the name Liquid uses for a ResourceLimitError
+type MemoryError = ResourceLimitError
+
+func (e ResourceLimitError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches ResourceLimitErrors, with the same message if the target has one
+func (e ResourceLimitError) Is(target error) bool {
+	t, ok := target.(ResourceLimitError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e ResourceLimitError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// StackLevelError is the analog to Liquid::StackLevelError, raised when blocks
+// are nested too deeply while parsing, or renders recurse too deeply
+type StackLevelError struct{ Detail }
+
+func (e StackLevelError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches StackLevelErrors, with the same message if the target has one
+func (e StackLevelError) Is(target error) bool {
+	t, ok := target.(StackLevelError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e StackLevelError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// ZeroDivisionError is the analog to Liquid::ZeroDivisionError, raised when
+// divided_by or modulo are given a zero divisor
+type ZeroDivisionError struct{ Detail }
+
+func (e ZeroDivisionError) Error() string {
+	return "Liquid error: divided by 0"
+}
+
+// Is matches any ZeroDivisionError
+func (e ZeroDivisionError) Is(target error) bool {
+	_, ok := target.(ZeroDivisionError)
+	return ok
+}
+
+func (e ZeroDivisionError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// FloatDomainError is the analog to Liquid::FloatDomainError, raised when
+// NaN or Infinity are rounded to an integer
+type FloatDomainError struct{ Detail }
+
+func (e FloatDomainError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches FloatDomainErrors, with the same message if the target has one
+func (e FloatDomainError) Is(target error) bool {
+	t, ok := target.(FloatDomainError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e FloatDomainError) at(pos Position) error {
+	e.Position = pos
+	return e
+}
+
+// ContextError is the analog to Liquid::ContextError, raised when the scopes
+// of a render's Context are used incorrectly
+type ContextError struct{ Detail }
+
+func (e ContextError) Error() string {
+	return "Liquid error: " + e.message()
+}
+
+// Is matches ContextErrors, with the same message if the target has one
+func (e ContextError) Is(target error) bool {
+	t, ok := target.(ContextError)
+	return ok && e.matches(t.Detail)
+}
+
+func (e ContextError) at(pos Position) error {
+	e.Position = pos
+	return e
 }
 
-// ErrNotFound wraps a missing variable error
-func ErrNotFound(variable string) error {
-	return LiquidError(fmt.Sprintf("Liquid::ErrorNotFound %v", variable), nil)
+// sprintf only formats messages with arguments, so that a message can contain a %
+func sprintf(format string, a ...interface{}) string {
+	if len(a) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, a...)
 }
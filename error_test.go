@@ -0,0 +1,98 @@
+package liquid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorCategories(t *testing.T) {
+	vars := Vars{"product": Vars{"title": "Shoe"}}
+
+	tests := []struct {
+		markup   string
+		category error
+		opts     []Option
+	}{
+		{`{% nope %}`, SyntaxError{}, nil},
+		{`{{ a | | b }}`, SyntaxError{}, nil},
+		{`{% if a b %}{% endif %}`, SyntaxError{}, nil},
+		{`{{ 'a' | append }}`, ArgumentError{}, nil},
+		{`{% if 'a' > 1 %}{% endif %}`, ArgumentError{}, nil},
+		{`{{ product.vendor }}`, UndefinedVariable{}, []Option{WithStrictVariables()}},
+		{`{{ product.title | upcse }}`, UndefinedFilter{}, []Option{WithStrictFilters()}},
+		{`{{ 1 | modulo: 0 }}`, ZeroDivisionError{}, nil},
+		{`{{ (1..3) }}`, ArgumentError{}, nil},
+	}
+
+	categories := []error{SyntaxError{}, ArgumentError{}, UndefinedVariable{}, UndefinedFilter{},
+		FileSystemError{}, ResourceLimitError{}, ZeroDivisionError{}, FloatDomainError{}, ContextError{}}
+
+	for _, test := range tests {
+		tpl, err := ParseTemplate("\n  "+test.markup, test.opts...)
+		if err == nil {
+			_, err = tpl.Render(vars, test.opts...)
+		}
+
+		for _, category := range categories {
+			if is := errors.Is(err, category); is != (category == test.category) {
+				t.Errorf("%v errors.Is(%v, %T) = %v", test.markup, err, category, is)
+			}
+		}
+
+		var located Error
+		if !errors.As(err, &located) || located.Position != (Position{Line: 2, Column: 3}) {
+			t.Errorf("%v error at the wrong position: %v", test.markup, err)
+		}
+	}
+
+	// errors raised by tags nested in blocks are at the nested tag
+	_, err := ParseTemplate(`{% if a %}x{% else y %}z{% endif %}`)
+	var located Error
+	if !errors.Is(err, SyntaxError{Detail{Message: "else doesn't accept params"}}) || !errors.As(err, &located) || located.Column != 12 {
+		t.Errorf("want a SyntaxError at the else tag, got: %v", err)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	tpl, _ := ParseTemplate(`{{ a }}{{ a | upcse }}`)
+	_, err := tpl.Render(nil, WithStrictFilters())
+
+	var undefined UndefinedFilter
+	if !errors.As(err, &undefined) {
+		t.Fatalf("want an UndefinedFilter, got: %v", err)
+	}
+	if undefined.Name != "upcse" || undefined.Position != (Position{Line: 1, Column: 8}) {
+		t.Errorf("unexpected details: %+v", undefined)
+	}
+	if !errors.Is(err, UndefinedFilter{Name: "upcse"}) || errors.Is(err, UndefinedFilter{Name: "upcase"}) {
+		t.Error("UndefinedFilters should match by name")
+	}
+
+	// the underlying errors of the parser are wrapped
+	_, err = ParseStrict("a | | b")
+	var syntaxErr SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Err == nil || errors.Unwrap(syntaxErr) != syntaxErr.Err {
+		t.Errorf("want a SyntaxError wrapping the parser's error, got: %#v", err)
+	}
+
+	// sentinels match by message
+	if !errors.Is(ErrNoScope, ContextError{}) || errors.Is(ErrNoScope, ErrVarNotFound) {
+		t.Error("ContextErrors should match by message")
+	}
+	if !errors.Is(ResourceLimitError{Detail{Message: "Memory limits exceeded"}}, MemoryError{}) {
+		t.Error("MemoryError should be a ResourceLimitError")
+	}
+}
+
+func TestParseExpressionDoesntPanic(t *testing.T) {
+	for markup, want := range map[string]Expression{
+		"99999999999999999999": floatExpr(99999999999999999999),
+		"(1..3)":               rangeExpr{1, 3},
+	} {
+		if got := ParseExpression(markup); got != want {
+			t.Errorf("ParseExpression(%q) want: %#v, got: %#v", markup, want, got)
+		}
+	}
+	ParseExpression("1.2.3")
+	ParseExpression("(a..99999999999999999999)")
+}
@@ -2,8 +2,12 @@ package liquid
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Expression objects contain specific types of usable data
@@ -12,7 +16,7 @@ type Expression interface {
 	// Evaluate with the supplied Context
 	// XXX: not so certain this is actually necessary, will need to review once
 	// more non-primitives have been implemented
-	Evaluate(Context) Expression
+	Evaluate(Context) (Expression, error)
 	// Name might be better as a helper method that switches on type
 	Name() string
 }
@@ -22,9 +26,8 @@ var (
 	Nil   = nilExpr{}
 	True  = boolExpr(true)
 	False = boolExpr(false)
-	// XXX: MethodLiteral isn't implemented
-	//   'blank'.freeze => MethodLiteral.new(:blank?, '').freeze,
-	//   'empty'.freeze => MethodLiteral.new(:empty?, '').freeze
+	Blank = methodLiteralExpr{method: "blank?"}
+	Empty = methodLiteralExpr{method: "empty?"}
 
 	// Regexes for parsing different types of literals
 	singleQuotedStringRegex = regexp.MustCompile(`(?ms)\A'(.*)'\z`)
@@ -45,9 +48,10 @@ func ParseExpression(markup string) Expression {
 		return False
 	case "true":
 		return True
-		// XXX: implement
-		// case "blank", "empty":
-		// 	return EmptyLiteral{}
+	case "blank":
+		return Blank
+	case "empty":
+		return Empty
 	}
 
 	if singleQuotedStringRegex.MatchString(markup) || doubleQuotedStringRegex.MatchString(markup) {
@@ -55,43 +59,38 @@ func ParseExpression(markup string) Expression {
 	}
 
 	if integerRegex.MatchString(markup) {
-		value, err := strconv.Atoi(markup)
-		if err != nil {
-			// XXX: this needs a real handler
-			panic(err)
+		// integers too big for an int are kept as floats
+		if value, err := strconv.Atoi(markup); err == nil {
+			return integerExpr(value)
 		}
-		return integerExpr(value)
 	}
 
 	if submatch := rangeRegex.FindAllStringSubmatch(markup, 1); len(submatch) > 0 {
-		start, err := strconv.Atoi(submatch[0][0])
-		if err != nil {
-			panic(err)
+		start, startErr := strconv.Atoi(submatch[0][1])
+		end, endErr := strconv.Atoi(submatch[0][2])
+		if startErr == nil && endErr == nil {
+			return rangeExpr{start, end}
 		}
-
-		end, err := strconv.Atoi(submatch[0][1])
-		if err != nil {
-			panic(err)
-		}
-		return rangeExpr{start, end}
 	}
 
-	if floatRegex.MatchString(markup) {
-		f, err := strconv.ParseFloat(markup, 64)
-		if err != nil {
-			panic(err)
+	if floatRegex.MatchString(markup) || integerRegex.MatchString(markup) {
+		if f, err := strconv.ParseFloat(markup, 64); err == nil {
+			return floatExpr(f)
 		}
-		return floatExpr(f)
 	}
 
-	return ParseVariableLookup(markup)
+	// markup with nothing to look up, such as `...`, is nil
+	if lookup := ParseVariableLookup(markup); lookup != nil {
+		return lookup
+	}
+	return Nil
 }
 
 // Base expression types
 
 type nilExpr struct{}
 
-func (e nilExpr) Evaluate(c Context) Expression { return e }
+func (e nilExpr) Evaluate(c Context) (Expression, error) { return e, nil }
 
 func (e nilExpr) Name() string {
 	return "nil"
@@ -99,8 +98,8 @@ func (e nilExpr) Name() string {
 
 type boolExpr bool
 
-func (e boolExpr) Evaluate(c Context) Expression {
-	return e
+func (e boolExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e boolExpr) Name() string {
@@ -109,8 +108,8 @@ func (e boolExpr) Name() string {
 
 type stringExpr string
 
-func (e stringExpr) Evaluate(c Context) Expression {
-	return e
+func (e stringExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e stringExpr) Name() string {
@@ -119,8 +118,8 @@ func (e stringExpr) Name() string {
 
 type integerExpr int
 
-func (e integerExpr) Evaluate(c Context) Expression {
-	return e
+func (e integerExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e integerExpr) Name() string {
@@ -129,8 +128,8 @@ func (e integerExpr) Name() string {
 
 type floatExpr float64
 
-func (e floatExpr) Evaluate(c Context) Expression {
-	return e
+func (e floatExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e floatExpr) Name() string {
@@ -142,8 +141,8 @@ type rangeExpr struct {
 	end   int
 }
 
-func (e rangeExpr) Evaluate(c Context) Expression {
-	panic("nope")
+func (e rangeExpr) Evaluate(c Context) (Expression, error) {
+	return nil, argumentError("ranges aren't supported")
 }
 
 func (e rangeExpr) Name() string {
@@ -153,8 +152,8 @@ func (e rangeExpr) Name() string {
 // literalExpr acts like an atom
 type literalExpr string
 
-func (e literalExpr) Evaluate(c Context) Expression {
-	return e
+func (e literalExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e literalExpr) Name() string {
@@ -163,10 +162,181 @@ func (e literalExpr) Name() string {
 
 type arrayExpr []interface{}
 
-func (e arrayExpr) Evaluate(c Context) Expression {
-	return e
+func (e arrayExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
 }
 
 func (e arrayExpr) Name() string {
 	return "some array, dunno lol"
 }
+
+type hashExpr map[string]interface{}
+
+func (e hashExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
+}
+
+func (e hashExpr) Name() string {
+	return inspect(e, nil)
+}
+
+// objectExpr wraps any Go value that doesn't have a more specific Expression type
+type objectExpr struct {
+	value interface{}
+}
+
+func (e objectExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
+}
+
+func (e objectExpr) Name() string {
+	return toString(e)
+}
+
+type dropExpr struct {
+	drop Drop
+}
+
+func (e dropExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
+}
+
+func (e dropExpr) Name() string {
+	return toString(e)
+}
+
+// methodLiteralExpr is the analog to Liquid::Expression::MethodLiteral, and is
+// used by `blank` and `empty`. When compared with another value it calls the
+// named method on that value rather than checking for equality.
+type methodLiteralExpr struct {
+	method string
+}
+
+func (e methodLiteralExpr) Evaluate(c Context) (Expression, error) {
+	return e, nil
+}
+
+// Name is always empty, MethodLiterals render as an empty string
+func (e methodLiteralExpr) Name() string {
+	return ""
+}
+
+// send calls the method on the supplied expression. Values which don't respond
+// to the method return false, just as the nil result would be falsy in ruby.
+//
+// empty? is true for strings, arrays and hashes with no contents. blank? follows the
+// ActiveSupport definition: nil, false, empty collections and whitespace-only strings
+// are blank. Drops answer for themselves by responding to "empty?" or "blank?"
+func (e methodLiteralExpr) send(v Expression) bool {
+	switch v.(type) {
+	case stringExpr:
+		if e == Blank {
+			return strings.TrimSpace(string(v.(stringExpr))) == ""
+		}
+		return len(v.(stringExpr)) == 0
+	case arrayExpr:
+		return len(v.(arrayExpr)) == 0
+	case hashExpr:
+		return len(v.(hashExpr)) == 0
+	case dropExpr:
+		result, _ := v.(dropExpr).drop.InvokeDrop(e.method).(bool)
+		return result
+	case nilExpr, nil:
+		return e == Blank
+	case boolExpr:
+		return e == Blank && !bool(v.(boolExpr))
+	}
+	return false
+}
+
+// toString converts an expression into its rendered form, following the
+// semantics of ruby's to_s. Arrays are joined without a separator
+func toString(e Expression) string {
+	return revealString(e, nil)
+}
+
+// revealString is toString, with the values the policy denies, however deeply
+// they're nested, rendered as nil. Go values are never printed with fmt, as that
+// would show the fields of structs no matter what the policy allows
+func revealString(e Expression, policy *ReflectionPolicy) string {
+	e = policy.reveal(e)
+	switch e.(type) {
+	case nil, nilExpr, methodLiteralExpr:
+		return ""
+	case floatExpr:
+		f := strconv.FormatFloat(float64(e.(floatExpr)), 'f', -1, 64)
+		if !strings.ContainsAny(f, ".") {
+			f += ".0"
+		}
+		return f
+	case arrayExpr:
+		var output string
+		for _, value := range e.(arrayExpr) {
+			output += revealString(interfaceToExpression(value), policy)
+		}
+		return output
+	case hashExpr:
+		return inspect(e, policy)
+	case dropExpr:
+		if s, ok := e.(dropExpr).drop.(fmt.Stringer); ok {
+			return s.String()
+		}
+		return ""
+	case objectExpr:
+		value := e.(objectExpr).value
+		if t, ok := value.(time.Time); ok {
+			return t.Format("2006-01-02 15:04:05 -0700")
+		}
+		if s, ok := value.(fmt.Stringer); ok {
+			return s.String()
+		}
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.Ptr:
+			if elem := v.Elem(); elem.Kind() != reflect.Ptr && elem.Kind() != reflect.Interface {
+				return revealString(interfaceToExpression(elem.Interface()), policy)
+			}
+		case reflect.Complex64, reflect.Complex128:
+			return fmt.Sprint(value)
+		}
+		// structs, funcs and channels have no output
+		return ""
+	}
+	return e.Name()
+}
+
+// inspect is the analog to ruby's inspect, used to render the values in hashes
+// like Hash#to_s does, so {"name"=>"Ada", "tags"=>["a", nil]}. Keys are sorted,
+// as Go maps have no order
+func inspect(e Expression, policy *ReflectionPolicy) string {
+	e = policy.reveal(e)
+	switch v := e.(type) {
+	case nil, nilExpr:
+		return "nil"
+	case stringExpr:
+		return strconv.Quote(string(v))
+	case arrayExpr:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = inspect(interfaceToExpression(item), policy)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case hashExpr:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, key := range keys {
+			entries[i] = strconv.Quote(key) + "=>" + inspect(interfaceToExpression(v[key]), policy)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case objectExpr, dropExpr:
+		// values with no output are shown by their type, like ruby's #<User>
+		if s := revealString(e, policy); s != "" {
+			return s
+		}
+		return fmt.Sprintf("#<%T>", expressionToInterface(e))
+	}
+	return revealString(e, policy)
+}
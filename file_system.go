@@ -0,0 +1,80 @@
+package liquid
+
+// Partial template loading from file_system.rb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var templateNameRegexp = regexp.MustCompile(`\A[^./][a-zA-Z0-9_/]+\z`)
+
+// FileSystem loads the partial templates used by tags such as include. Each
+// engine can have its own, see WithFileSystem
+type FileSystem interface {
+	// ReadTemplateFile returns the source of the named template, or a FileSystemError
+	ReadTemplateFile(name string) (string, error)
+}
+
+// BlankFileSystem is the default FileSystem, which doesn't allow any templates to be loaded
+type BlankFileSystem struct{}
+
+// ReadTemplateFile always fails
+func (fs BlankFileSystem) ReadTemplateFile(name string) (string, error) {
+	return "", FileSystemError{Detail{Message: "This liquid context does not allow includes."}}
+}
+
+// LocalFileSystem loads templates from a directory, following the Rails naming
+// convention for partials by default, so that "product" is read from _product.liquid
+// and "shop/product" from shop/_product.liquid. Names can only contain letters,
+// digits, underscores and slashes, so templates can't be read from outside Root
+type LocalFileSystem struct {
+	Root string
+	// Pattern formats the base name of a template into its file name, if it's
+	// empty _%s.liquid is used
+	Pattern string
+}
+
+// ReadTemplateFile reads the named template from the directory
+func (fs LocalFileSystem) ReadTemplateFile(name string) (string, error) {
+	path, err := fs.FullPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return "", FileSystemError{Detail{Message: fmt.Sprintf("No such template '%v'", name), Err: err}}
+	}
+	return string(source), nil
+}
+
+// FullPath returns the path of the file the named template is read from
+func (fs LocalFileSystem) FullPath(name string) (string, error) {
+	if !templateNameRegexp.MatchString(name) {
+		return "", FileSystemError{Detail{Message: fmt.Sprintf("Illegal template name '%v'", name)}}
+	}
+
+	pattern := fs.Pattern
+	if pattern == "" {
+		pattern = "_%s.liquid"
+	}
+	dir, base := filepath.Split(filepath.FromSlash(name))
+	path := filepath.Join(fs.Root, dir, fmt.Sprintf(pattern, base))
+
+	root, err := filepath.Abs(fs.Root)
+	if err != nil {
+		return "", FileSystemError{Detail{Err: err}}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", FileSystemError{Detail{Err: err}}
+	}
+	if !strings.HasPrefix(abs, root) {
+		return "", FileSystemError{Detail{Message: fmt.Sprintf("Illegal template path '%v'", abs)}}
+	}
+	return path, nil
+}
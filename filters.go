@@ -0,0 +1,261 @@
+package liquid
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var decimalRegexp = regexp.MustCompile(`\A-?\d+\.\d+\z`)
+
+// filterFunc is the signature of all filter implementations. The input and
+// arguments are evaluated and unwrapped into plain Go values before the call
+type filterFunc func(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+
+// contextFilterFunc is the signature of filters which depend on the render they're
+// called from, such as date filters which need the time zone and current time
+type contextFilterFunc func(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+
+// standardFilters are the filters available to all templates, the analog to Liquid::StandardFilters
+var standardFilters = map[string]filterFunc{
+	// string filters
+	"append":         appendFilter,
+	"prepend":        prependFilter,
+	"capitalize":     capitalizeFilter,
+	"upcase":         upcaseFilter,
+	"downcase":       downcaseFilter,
+	"strip":          stripFilter,
+	"lstrip":         lstripFilter,
+	"rstrip":         rstripFilter,
+	"strip_newlines": stripNewlinesFilter,
+	"remove":         removeFilter,
+	"remove_first":   removeFirstFilter,
+	"remove_last":    removeLastFilter,
+	"replace":        replaceFilter,
+	"replace_first":  replaceFirstFilter,
+	"replace_last":   replaceLastFilter,
+	"slice":          sliceFilter,
+	"split":          splitFilter,
+	"truncate":       truncateFilter,
+	"truncatewords":  truncatewordsFilter,
+	"default":        defaultFilter,
+
+	// array filters
+	"first":   firstFilter,
+	"last":    lastFilter,
+	"size":    sizeFilter,
+	"reverse": reverseFilter,
+	"concat":  concatFilter,
+
+	// math filters
+	"plus":       plusFilter,
+	"minus":      minusFilter,
+	"times":      timesFilter,
+	"divided_by": dividedByFilter,
+	"modulo":     moduloFilter,
+	"round":      roundFilter,
+	"ceil":       ceilFilter,
+	"floor":      floorFilter,
+	"abs":        absFilter,
+	"at_least":   atLeastFilter,
+	"at_most":    atMostFilter,
+
+	// html filters
+	"escape":                 escapeFilter,
+	"h":                      escapeFilter,
+	"escape_once":            escapeOnceFilter,
+	"url_encode":             urlEncodeFilter,
+	"url_decode":             urlDecodeFilter,
+	"strip_html":             stripHTMLFilter,
+	"newline_to_br":          newlineToBrFilter,
+	"base64_encode":          base64EncodeFilter,
+	"base64_decode":          base64DecodeFilter,
+	"base64_url_safe_encode": base64URLSafeEncodeFilter,
+	"base64_url_safe_decode": base64URLSafeDecodeFilter,
+}
+
+// contextFilters are the standard filters which depend on the render context
+var contextFilters = map[string]contextFilterFunc{
+	"date":     dateFilter,
+	"date_add": dateAddFilter,
+	"time_ago": timeAgoFilter,
+
+	// array filters which look up the properties of items, or render them
+	"join":         joinFilter,
+	"uniq":         uniqFilter,
+	"compact":      compactFilter,
+	"map":          mapFilter,
+	"where":        whereFilter,
+	"reject":       rejectFilter,
+	"find":         findFilter,
+	"find_index":   findIndexFilter,
+	"has":          hasFilter,
+	"sort":         sortFilter,
+	"sort_natural": sortNaturalFilter,
+	"sum":          sumFilter,
+}
+
+// lookupFilter finds a standard filter by name
+func lookupFilter(name string) (contextFilterFunc, bool) {
+	if fn, ok := contextFilters[name]; ok {
+		return fn, true
+	}
+
+	fn, ok := standardFilters[name]
+	if !ok {
+		return nil, false
+	}
+	return func(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		return fn(input, args, kwargs)
+	}, true
+}
+
+// apply evaluates the filter's arguments and invokes it on the input. Filters which
+// don't exist leave the input untouched, unless strict filters are enabled
+func (f Filter) apply(input Expression, ctx *Context) (Expression, error) {
+	fn, ok := ctx.engine.orDefault().findFilter(f.name)
+	if !ok {
+		if ctx.options.strictFilters {
+			return nil, UndefinedFilter{Name: f.name}
+		}
+		return input, nil
+	}
+
+	args := make([]interface{}, len(f.args))
+	for i, arg := range f.args {
+		value, err := arg.Evaluate(*ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = expressionToInterface(value)
+	}
+
+	var kwargs map[string]interface{}
+	if len(f.kwargs) > 0 {
+		kwargs = make(map[string]interface{}, len(f.kwargs))
+		for k, arg := range f.kwargs {
+			value, err := arg.Evaluate(*ctx)
+			if err != nil {
+				return nil, err
+			}
+			kwargs[k] = expressionToInterface(value)
+		}
+	}
+
+	output, err := fn(ctx, expressionToInterface(input), args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	return interfaceToExpression(output), nil
+}
+
+// checkArgs validates the number of arguments passed to a filter. As in ruby
+// the input counts towards the number given, max is -1 if there is no maximum
+func checkArgs(args []interface{}, min, max int) error {
+	if len(args) >= min && (max < 0 || len(args) <= max) {
+		return nil
+	}
+
+	expected := strconv.Itoa(min + 1)
+	switch {
+	case max < 0:
+		expected += "+"
+	case max > min:
+		expected += fmt.Sprintf("..%v", max+1)
+	}
+
+	return argumentError("wrong number of arguments (given %v, expected %v)", len(args)+1, expected)
+}
+
+// optionalArg returns the argument at index i, or fallback if it wasn't supplied
+func optionalArg(args []interface{}, i int, fallback interface{}) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return fallback
+}
+
+// toS converts a value to a string, the analog to ruby's to_s
+func toS(v interface{}) string {
+	return toString(interfaceToExpression(v))
+}
+
+// toInteger is the analog to Liquid::Utils.to_integer, it accepts integers and
+// anything whose string form is one, such as "12", "-3" or "0x1A"
+func toInteger(v interface{}) (int, error) {
+	if i, ok := interfaceToExpression(v).(integerExpr); ok {
+		return int(i), nil
+	}
+
+	s := strings.Replace(strings.TrimSpace(toS(v)), "_", "", -1)
+	i, err := strconv.ParseInt(s, 0, 0)
+	if err != nil {
+		return 0, argumentError("invalid integer")
+	}
+	return int(i), nil
+}
+
+// toNumber is the analog to Liquid::Utils.to_number. Integers are returned as ints, while
+// floats and decimal strings become exact *big.Rat values, as ruby uses BigDecimal to avoid
+// results like 0.1 + 0.2 = 0.30000000000000004. Other strings are converted with ruby's to_i
+// semantics, and anything else is 0
+func toNumber(v interface{}) interface{} {
+	e := interfaceToExpression(v)
+	switch e.(type) {
+	case integerExpr:
+		return int(e.(integerExpr))
+	case floatExpr:
+		f := float64(e.(floatExpr))
+		if r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64)); ok {
+			return r
+		}
+		// NaN and infinity can't be represented exactly
+		return f
+	case stringExpr:
+		s := strings.Trim(string(e.(stringExpr)), rubyWhitespace)
+		if decimalRegexp.MatchString(s) {
+			r, _ := new(big.Rat).SetString(s)
+			return r
+		}
+		return rubyToI(s)
+	}
+	return 0
+}
+
+// rubyToI is the analog to ruby's String#to_i, which parses as much of
+// a leading integer as it can, and returns 0 if there isn't one
+func rubyToI(s string) int {
+	s = strings.TrimLeft(s, rubyWhitespace)
+
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	for end < len(s) {
+		if isDigit(s[end]) {
+			end++
+		} else if s[end] == '_' && end > 0 && isDigit(s[end-1]) && end+1 < len(s) && isDigit(s[end+1]) {
+			end++
+		} else {
+			break
+		}
+	}
+
+	i, err := strconv.Atoi(strings.Replace(s[:end], "_", "", -1))
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// fromRat converts an exact decimal back into a float, as ruby's BigDecimal#to_f
+func fromRat(r *big.Rat) float64 {
+	f, _ := r.Float64()
+	return f
+}
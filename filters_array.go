@@ -0,0 +1,557 @@
+package liquid
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Array filters from Liquid::StandardFilters. Their input is converted to an
+// array the same way as ruby's InputIterator: nested arrays are flattened,
+// IterableDrops are expanded, nil is empty and anything else is wrapped in
+// a single element array
+
+// inputIterator is the analog to Liquid::StandardFilters::InputIterator
+func inputIterator(input interface{}) []interface{} {
+	if drop, ok := input.(IterableDrop); ok {
+		return drop.Items()
+	}
+
+	switch e := interfaceToExpression(input); e.(type) {
+	case nilExpr:
+		return []interface{}{}
+	case arrayExpr:
+		return flatten(e.(arrayExpr), []interface{}{})
+	}
+	return []interface{}{input}
+}
+
+func flatten(array arrayExpr, output []interface{}) []interface{} {
+	for _, value := range array {
+		if nested, ok := interfaceToExpression(value).(arrayExpr); ok {
+			output = flatten(nested, output)
+		} else {
+			output = append(output, value)
+		}
+	}
+	return output
+}
+
+// propertyError is raised when a property can't be selected from an item
+func propertyError(property interface{}) error {
+	return argumentError("cannot select the property '%v'", toS(property))
+}
+
+// itemProperty is the analog to item[property] in the ruby filters. ok is false
+// for items which can't be indexed at all. Drops are looked up with the render's
+// context.Context, and other Go values as far as its ReflectionPolicy allows
+func itemProperty(ctx *Context, item, property interface{}) (value interface{}, ok bool, err error) {
+	policy := ctx.options.reflection
+	e := policy.reveal(interfaceToExpression(item))
+	switch e.(type) {
+	case hashExpr, dropExpr:
+		value, _, err := ctx.lookupAndEvaluate(e, interfaceToExpression(property))
+		return expressionToInterface(policy.reveal(value)), true, err
+	case objectExpr:
+		if policy == nil {
+			break
+		}
+		value, _, err := ctx.lookupAndEvaluate(e, stringExpr(toS(property)))
+		return expressionToInterface(policy.reveal(value)), true, err
+	case stringExpr:
+		// ruby's String#[] returns the substring if it is present
+		if s := toS(property); strings.Contains(string(e.(stringExpr)), s) {
+			return s, true, nil
+		}
+		return nil, true, nil
+	case nilExpr:
+		return nil, false, nil
+	}
+	return nil, false, propertyError(property)
+}
+
+// {{ product.tags | join: ', ' }}
+//
+// Items the render's ReflectionPolicy denies are joined as nil
+func joinFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	glue := toS(optionalArg(args, 0, " "))
+	items := inputIterator(input)
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = revealString(interfaceToExpression(item), ctx.options.reflection)
+	}
+	return strings.Join(parts, glue), nil
+}
+
+// first returns the first item of an array. Like ruby's it's nil for anything else,
+// including strings, which don't respond to first
+func firstFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch e := interfaceToExpression(input); e.(type) {
+	case arrayExpr:
+		if array := e.(arrayExpr); len(array) > 0 {
+			return array[0], nil
+		}
+	case dropExpr:
+		if drop, ok := input.(IterableDrop); ok {
+			if items := drop.Items(); len(items) > 0 {
+				return items[0], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// last returns the last item of an array, or nil for anything else, as first does
+func lastFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch e := interfaceToExpression(input); e.(type) {
+	case arrayExpr:
+		if array := e.(arrayExpr); len(array) > 0 {
+			return array[len(array)-1], nil
+		}
+	case dropExpr:
+		if drop, ok := input.(IterableDrop); ok {
+			if items := drop.Items(); len(items) > 0 {
+				return items[len(items)-1], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// size returns the number of items in an array or hash, or characters in a string
+func sizeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch e := interfaceToExpression(input); e.(type) {
+	case stringExpr:
+		return utf8.RuneCountInString(string(e.(stringExpr))), nil
+	case arrayExpr:
+		return len(e.(arrayExpr)), nil
+	case hashExpr:
+		return len(e.(hashExpr)), nil
+	case integerExpr:
+		// ruby's Integer#size is the number of bytes in the machine representation
+		return 8, nil
+	case dropExpr:
+		if drop, ok := input.(IterableDrop); ok {
+			return len(drop.Items()), nil
+		}
+		if size := e.(dropExpr).drop.InvokeDrop("size"); size != nil {
+			return size, nil
+		}
+	}
+	return 0, nil
+}
+
+func reverseFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	items := inputIterator(input)
+	output := make([]interface{}, len(items))
+	for i, item := range items {
+		output[len(items)-1-i] = item
+	}
+	return output, nil
+}
+
+// uniq removes duplicate items, or items with duplicate values of the property.
+// Like ruby's eql?, 1 and 1.0 are considered different values
+func uniqFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	items := inputIterator(input)
+	property := optionalArg(args, 0, nil)
+
+	output := []interface{}{}
+	var seen []Expression
+Items:
+	for _, item := range items {
+		key := item
+		if property != nil {
+			var err error
+			if key, _, err = itemProperty(ctx, item, property); err != nil {
+				return nil, err
+			}
+		}
+
+		e := interfaceToExpression(key)
+		for _, other := range seen {
+			if reflect.DeepEqual(e, other) {
+				continue Items
+			}
+		}
+		seen = append(seen, e)
+		output = append(output, item)
+	}
+	return output, nil
+}
+
+// compact removes nil items, or items where the property is nil
+func compactFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	property := optionalArg(args, 0, nil)
+	output := []interface{}{}
+	for _, item := range inputIterator(input) {
+		value := item
+		if property != nil {
+			var err error
+			if value, _, err = itemProperty(ctx, item, property); err != nil {
+				return nil, err
+			}
+		}
+		if !isNil(value) {
+			output = append(output, item)
+		}
+	}
+	return output, nil
+}
+
+// {{ fruits | concat: vegetables }}
+func concatFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	other, ok := interfaceToExpression(args[0]).(arrayExpr)
+	if !ok {
+		return nil, argumentError("concat filter requires an array argument")
+	}
+	return append(inputIterator(input), other...), nil
+}
+
+// map selects a property from each item
+//
+// {{ products | map: 'title' | join: ', ' }}
+func mapFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	property := args[0]
+	items := inputIterator(input)
+	output := make([]interface{}, len(items))
+	for i, item := range items {
+		if toS(property) == "to_liquid" {
+			output[i] = item
+			continue
+		}
+
+		value, _, err := itemProperty(ctx, item, property)
+		if err != nil {
+			return nil, err
+		}
+		output[i] = value
+	}
+	return output, nil
+}
+
+// selectItems is the analog to filter_array, shared by where, reject, find, find_index and
+// has. Without a target value items match if the property is truthy, otherwise it must be
+// equal to the target. visit is called for each item and returns false to stop early
+func selectItems(ctx *Context, input interface{}, args []interface{}, visit func(i int, item interface{}, matched bool) bool) error {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return err
+	}
+
+	property := args[0]
+	target := optionalArg(args, 1, nil)
+
+	for i, item := range inputIterator(input) {
+		value, _, err := itemProperty(ctx, item, property)
+		if err != nil {
+			return err
+		}
+
+		var matched bool
+		if target == nil {
+			matched = isTruthy(interfaceToExpression(value))
+		} else {
+			matched = liquidEqual(interfaceToExpression(value), interfaceToExpression(target))
+		}
+
+		if !visit(i, item, matched) {
+			break
+		}
+	}
+	return nil
+}
+
+// where selects the items with a truthy property, or with a property equal to the target
+//
+// {{ products | where: 'available' }}
+// {{ products | where: 'type', 'kitchen' }}
+func whereFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	output := []interface{}{}
+	err := selectItems(ctx, input, args, func(i int, item interface{}, matched bool) bool {
+		if matched {
+			output = append(output, item)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// reject is the inverse of where
+func rejectFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	output := []interface{}{}
+	err := selectItems(ctx, input, args, func(i int, item interface{}, matched bool) bool {
+		if !matched {
+			output = append(output, item)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// find returns the first item that where would select, or nil
+func findFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var output interface{}
+	err := selectItems(ctx, input, args, func(i int, item interface{}, matched bool) bool {
+		if matched {
+			output = item
+		}
+		return !matched
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// find_index returns the index of the first item that where would select, or nil
+func findIndexFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	var output interface{}
+	err := selectItems(ctx, input, args, func(i int, item interface{}, matched bool) bool {
+		if matched {
+			output = i
+		}
+		return !matched
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// has reports whether where would select any items
+func hasFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	output := false
+	err := selectItems(ctx, input, args, func(i int, item interface{}, matched bool) bool {
+		output = matched
+		return !matched
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// spaceship is the analog to ruby's <=> operator, ok is false if
+// the values can't be compared
+func spaceship(a, b interface{}) (int, bool) {
+	x, y := interfaceToExpression(a), interfaceToExpression(b)
+
+	if result, ok, err := compare(x, y); ok && err == nil {
+		return result, true
+	}
+
+	// arrays are compared item by item
+	if xs, ok := x.(arrayExpr); ok {
+		if ys, ok := y.(arrayExpr); ok {
+			for i := 0; i < len(xs) && i < len(ys); i++ {
+				if result, ok := spaceship(xs[i], ys[i]); !ok || result != 0 {
+					return result, ok
+				}
+			}
+			return compareOrdered(len(xs) < len(ys), len(xs) > len(ys)), true
+		}
+	}
+
+	if liquidEqual(x, y) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// nilSafeCompare sorts nil after everything else, and fails if values can't be compared
+func nilSafeCompare(a, b interface{}) (int, error) {
+	if result, ok := spaceship(a, b); ok {
+		return result, nil
+	}
+	if isNil(a) {
+		return 1, nil
+	}
+	if isNil(b) {
+		return -1, nil
+	}
+	return 0, argumentError("cannot sort values of incompatible types")
+}
+
+// nilSafeCasecmp compares the string forms of values ignoring ascii case, as ruby's
+// casecmp, and sorts nil after everything else
+func nilSafeCasecmp(a, b interface{}) (int, error) {
+	switch {
+	case isNil(a) && isNil(b):
+		return 0, nil
+	case isNil(a):
+		return 1, nil
+	case isNil(b):
+		return -1, nil
+	}
+	return strings.Compare(asciiDowncase(toS(a)), asciiDowncase(toS(b))), nil
+}
+
+func asciiDowncase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// sortItems sorts the input by cmp, optionally comparing the values of a property
+func sortItems(ctx *Context, input interface{}, args []interface{}, cmp func(a, b interface{}) (int, error)) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	items := inputIterator(input)
+	property := optionalArg(args, 0, nil)
+
+	keys := items
+	if property != nil {
+		keys = make([]interface{}, len(items))
+		for i, item := range items {
+			value, ok, err := itemProperty(ctx, item, property)
+			if err != nil {
+				return nil, err
+			}
+			// ruby only sorts by property if every item responds to []
+			if !ok {
+				return nil, nil
+			}
+			keys[i] = value
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+
+	var sortErr error
+	sort.SliceStable(order, func(i, j int) bool {
+		result, err := cmp(keys[order[i]], keys[order[j]])
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return result < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	output := make([]interface{}, len(items))
+	for i, index := range order {
+		output[i] = items[index]
+	}
+	return output, nil
+}
+
+// sort orders items by their natural order, with nil values last
+//
+// {{ products | sort: 'price' }}
+func sortFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return sortItems(ctx, input, args, nilSafeCompare)
+}
+
+// sort_natural orders items by their case-insensitive string value, with nil values last
+func sortNaturalFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	return sortItems(ctx, input, args, nilSafeCasecmp)
+}
+
+// sum adds the items, or the values of a property, converting them with
+// toNumber. The result is an integer unless any of the values were decimals
+//
+// {{ cart.items | sum: 'quantity' }}
+func sumFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	property := optionalArg(args, 0, nil)
+	total := 0
+	var decimal *big.Rat
+	var float *float64
+
+	for _, item := range inputIterator(input) {
+		value := item
+		if property != nil {
+			var ok bool
+			var err error
+			if value, ok, err = itemProperty(ctx, item, property); err != nil {
+				return nil, err
+			} else if !ok {
+				value = 0
+			}
+		}
+
+		switch n := toNumber(value); n.(type) {
+		case int:
+			total += n.(int)
+		case *big.Rat:
+			if decimal == nil {
+				decimal = new(big.Rat)
+			}
+			decimal.Add(decimal, n.(*big.Rat))
+		case float64:
+			f := n.(float64)
+			if float != nil {
+				f += *float
+			}
+			float = &f
+		}
+	}
+
+	switch {
+	case float != nil:
+		result := *float + float64(total)
+		if decimal != nil {
+			result += fromRat(decimal)
+		}
+		return result, nil
+	case decimal != nil:
+		return fromRat(decimal.Add(decimal, new(big.Rat).SetInt64(int64(total)))), nil
+	}
+	return total, nil
+}
@@ -0,0 +1,203 @@
+package liquid
+
+import "testing"
+
+// array filters from integration/standard_filter_test.rb
+
+type product struct {
+	title string
+	price interface{}
+}
+
+func (p product) InvokeDrop(key string) interface{} {
+	switch key {
+	case "title":
+		return p.title
+	case "price":
+		return p.price
+	}
+	return nil
+}
+
+type productCollection []interface{}
+
+func (c productCollection) InvokeDrop(key string) interface{} {
+	return nil
+}
+
+func (c productCollection) Items() []interface{} {
+	return c
+}
+
+func hash(values ...interface{}) map[string]interface{} {
+	h := map[string]interface{}{}
+	for i := 0; i < len(values); i += 2 {
+		h[values[i].(string)] = values[i+1]
+	}
+	return h
+}
+
+func TestJoin(t *testing.T) {
+	checkFilter(t, "join", args(1, 2, 3, 4), nil, nil, "1 2 3 4")
+	checkFilter(t, "join", args(1, 2, 3, 4), args(" - "), nil, "1 - 2 - 3 - 4")
+	checkFilter(t, "join", args(1, 2, 3, 4), args(1), nil, "1121314")
+	checkFilter(t, "join", []string{"a", "b"}, args(","), nil, "a,b")
+	checkFilter(t, "join", args(args(1, 2), args(3, args(4))), args(","), nil, "1,2,3,4")
+	checkFilter(t, "join", nil, nil, nil, "")
+	checkFilter(t, "join", "abc", nil, nil, "abc")
+}
+
+func TestFirstLast(t *testing.T) {
+	checkFilter(t, "first", args(1, 2, 3), nil, nil, 1)
+	checkFilter(t, "last", args(1, 2, 3), nil, nil, 3)
+	checkFilter(t, "first", args(), nil, nil, nil)
+	checkFilter(t, "last", args(), nil, nil, nil)
+	checkFilter(t, "first", "héllo", nil, nil, nil)
+	checkFilter(t, "last", "héllo", nil, nil, nil)
+	checkFilter(t, "first", "", nil, nil, nil)
+	checkTemplateRender(t, `{{ s | first }}{{ s | last }}{{ s.first }}`, Vars{"s": "abc"}, "")
+	checkFilter(t, "first", []int{7, 8}, nil, nil, 7)
+	checkFilter(t, "last", productCollection{"a", "b"}, nil, nil, "b")
+	checkFilter(t, "first", nil, nil, nil, nil)
+}
+
+func TestSize(t *testing.T) {
+	checkFilter(t, "size", args(1, 2), nil, nil, 2)
+	checkFilter(t, "size", args(), nil, nil, 0)
+	checkFilter(t, "size", nil, nil, nil, 0)
+	checkFilter(t, "size", "héllo", nil, nil, 5)
+	checkFilter(t, "size", hash("a", 1), nil, nil, 1)
+	checkFilter(t, "size", productCollection{1, 2, 3}, nil, nil, 3)
+}
+
+func TestReverse(t *testing.T) {
+	checkFilter(t, "reverse", args(1, 2, 3, 4), nil, nil, args(4, 3, 2, 1))
+	checkFilter(t, "reverse", []string{"a", "b"}, nil, nil, args("b", "a"))
+	checkFilter(t, "reverse", nil, nil, nil, []interface{}{})
+}
+
+func TestUniq(t *testing.T) {
+	checkFilter(t, "uniq", args("foo"), nil, nil, args("foo"))
+	checkFilter(t, "uniq", args(1, 1, 3, 2, 3, 1, 4, 3, 2, 1), nil, nil, args(1, 3, 2, 4))
+	checkFilter(t, "uniq", args(1, 1.0), nil, nil, args(1, 1.0))
+	checkFilter(t, "uniq", args(hash("a", 1), hash("a", 3), hash("a", 1)), args("a"), nil, args(hash("a", 1), hash("a", 3)))
+	checkFilter(t, "uniq", args(args(1, 2), args(1, 2)), nil, nil, args(1, 2))
+}
+
+func TestCompact(t *testing.T) {
+	checkFilter(t, "compact", args(1, nil, 2, nil), nil, nil, args(1, 2))
+	checkFilter(t, "compact", args(hash("a", 1), hash("a", nil)), args("a"), nil, args(hash("a", 1)))
+}
+
+func TestConcat(t *testing.T) {
+	checkFilter(t, "concat", args(1, 2), args(args("a", "b")), nil, args(1, 2, "a", "b"))
+	checkFilter(t, "concat", args(1, 2), args([]int{3}), nil, args(1, 2, 3))
+	checkFilter(t, "concat", nil, args(args(1)), nil, args(1))
+	checkFilterError(t, "concat", args(1, 2), 10)
+	checkFilterError(t, "concat", args(1, 2), nil)
+}
+
+func TestMap(t *testing.T) {
+	checkFilter(t, "map", args(hash("a", 1), hash("a", 2)), args("a"), nil, args(1, 2))
+	checkFilter(t, "map", args(hash("a", 1), hash("b", 2)), args("a"), nil, args(1, nil))
+	checkFilter(t, "map", args(product{title: "x"}, product{title: "y"}), args("title"), nil, args("x", "y"))
+	checkFilter(t, "map", args(hash("a", 1)), args("to_liquid"), nil, args(hash("a", 1)))
+	checkFilter(t, "map", args("foo", "bar"), args("oo"), nil, args("oo", nil))
+	checkFilter(t, "map", args(nil), args("a"), nil, args(nil))
+	checkFilterError(t, "map", args(1, 2), "a")
+}
+
+func TestMapOnNestedArrays(t *testing.T) {
+	input := args(args(hash("a", 1)), args(hash("a", 2)))
+	checkFilter(t, "map", input, args("a"), nil, args(1, 2))
+}
+
+func TestWhere(t *testing.T) {
+	input := args(
+		hash("handle", "alpha", "ok", true),
+		hash("handle", "beta", "ok", false),
+		hash("handle", "gamma", "ok", false),
+		hash("handle", "delta", "ok", true),
+	)
+
+	checkFilter(t, "where", input, args("ok", true), nil, args(input[0], input[3]))
+	checkFilter(t, "where", input, args("ok"), nil, args(input[0], input[3]))
+	checkFilter(t, "where", input, args("handle", "beta"), nil, args(input[1]))
+	checkFilter(t, "where", input, args("handle", "omega"), nil, []interface{}{})
+	checkFilter(t, "where", hash("a", "ok"), args("a", "ok"), nil, args(hash("a", "ok")))
+	checkFilter(t, "where", hash("a", "ok"), args("a", "not ok"), nil, []interface{}{})
+	checkFilter(t, "where", args(hash("n", 1), hash("n", 1.0), hash("n", 2)), args("n", 1), nil, args(hash("n", 1), hash("n", 1.0)))
+}
+
+func TestWhereStrings(t *testing.T) {
+	checkFilter(t, "where", args("alpha", "beta", "gamma"), args("ph"), nil, args("alpha"))
+}
+
+func TestRejectFindHas(t *testing.T) {
+	input := args(
+		hash("handle", "alpha", "ok", true),
+		hash("handle", "beta", "ok", false),
+		hash("handle", "gamma", "ok", true),
+	)
+
+	checkFilter(t, "reject", input, args("ok"), nil, args(input[1]))
+	checkFilter(t, "reject", input, args("handle", "alpha"), nil, args(input[1], input[2]))
+	checkFilter(t, "find", input, args("ok"), nil, input[0])
+	checkFilter(t, "find", input, args("handle", "gamma"), nil, input[2])
+	checkFilter(t, "find", input, args("handle", "omega"), nil, nil)
+	checkFilter(t, "find_index", input, args("handle", "gamma"), nil, 2)
+	checkFilter(t, "find_index", input, args("handle", "omega"), nil, nil)
+	checkFilter(t, "has", input, args("ok", false), nil, true)
+	checkFilter(t, "has", input, args("handle", "omega"), nil, false)
+	checkFilter(t, "has", args(), args("ok"), nil, false)
+	checkFilterError(t, "where", input)
+}
+
+func TestSort(t *testing.T) {
+	checkFilter(t, "sort", args(4, 3, 2, 1), nil, nil, args(1, 2, 3, 4))
+	checkFilter(t, "sort", args(3, 1.5, 2), nil, nil, args(1.5, 2, 3))
+	checkFilter(t, "sort", args("b", "a", "C"), nil, nil, args("C", "a", "b"))
+	checkFilter(t, "sort", args(hash("a", 4), hash("a", 3), hash("a", 1), hash("a", 2)), args("a"), nil,
+		args(hash("a", 1), hash("a", 2), hash("a", 3), hash("a", 4)))
+	checkFilter(t, "sort", args(hash("a", 2), hash("b", 1), hash("a", 1)), args("a"), nil,
+		args(hash("a", 1), hash("a", 2), hash("b", 1)))
+	checkFilter(t, "sort", args(3, nil, 1), nil, nil, args(1, 3, nil))
+	checkFilter(t, "sort", args(args(2, 1), args(1, 3)), nil, nil, args(1, 1, 2, 3))
+	checkFilter(t, "sort", args(product{title: "b"}, product{title: "a"}), args("title"), nil,
+		args(product{title: "a"}, product{title: "b"}))
+	checkFilterError(t, "sort", args(1, "a"))
+}
+
+func TestSortNatural(t *testing.T) {
+	checkFilter(t, "sort_natural", args("c", "D", "a", "B"), nil, nil, args("a", "B", "c", "D"))
+	checkFilter(t, "sort_natural", args("c", nil, "a"), nil, nil, args("a", "c", nil))
+	checkFilter(t, "sort_natural", args(hash("a", "b"), hash("a", "C"), hash("a", "A")), args("a"), nil,
+		args(hash("a", "A"), hash("a", "b"), hash("a", "C")))
+	checkFilter(t, "sort_natural", args(hash("a", "b"), hash("a", nil), hash("a", "A")), args("a"), nil,
+		args(hash("a", "A"), hash("a", "b"), hash("a", nil)))
+}
+
+func TestSum(t *testing.T) {
+	checkFilter(t, "sum", args(), nil, nil, 0)
+	checkFilter(t, "sum", args(1, 2), nil, nil, 3)
+	checkFilter(t, "sum", args(1, args(2, args(3))), nil, nil, 6)
+	checkFilter(t, "sum", args("1", "2", 3), nil, nil, 6)
+	checkFilter(t, "sum", args(1, "foo", nil), nil, nil, 1)
+	checkFilter(t, "sum", args(0.1, 0.2), nil, nil, 0.3)
+	checkFilter(t, "sum", args("0.1", 0.2, 1), nil, nil, 1.3)
+	checkFilter(t, "sum", args(hash("q", 1), hash("q", 2), hash("r", 3)), args("q"), nil, 3)
+	checkFilter(t, "sum", args(product{price: 1.5}, product{price: "2"}), args("price"), nil, 3.5)
+}
+
+func TestArrayFiltersInTemplates(t *testing.T) {
+	vars := Vars{
+		"products": productCollection{product{"Hat", 10}, product{"Scarf", 5}, product{"Gloves", 8}},
+		"numbers":  []int{3, 1, 2},
+	}
+	checkTemplateRender(t, `{{ products | map: 'title' | join: ', ' }}`, vars, "Hat, Scarf, Gloves")
+	checkTemplateRender(t, `{{ products | sort: 'price' | map: 'title' | join }}`, vars, "Scarf Gloves Hat")
+	checkTemplateRender(t, `{{ products | sum: 'price' }}`, vars, "23")
+	checkTemplateRender(t, `{{ numbers | sort | reverse | join: '' }}`, vars, "321")
+	checkTemplateRender(t, `{{ numbers | size }}`, vars, "3")
+	checkTemplateRender(t, `{{ 'a,b,b' | split: ',' | uniq | join: '-' }}`, vars, "a-b")
+}
@@ -0,0 +1,398 @@
+package liquid
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	contextType  = reflect.TypeOf((*context.Context)(nil)).Elem()
+	kwargsType   = reflect.TypeOf(Kwargs{})
+	keywordsType = reflect.TypeOf(Keywords{})
+)
+
+// Kwargs receives the keyword arguments of a filter registered with RegisterFilter,
+// keyed by their names as they're given in the template
+type Kwargs map[string]interface{}
+
+// Keywords marks a struct as receiving the keyword arguments of a filter registered
+// with RegisterFilter when it's embedded in it
+type Keywords struct{}
+
+// RegisterFilter makes a Go function available as a filter to the templates parsed
+// with ParseTemplate, so after
+//
+//	RegisterFilter("pluralize", func(count int, singular, plural string) string {
+//		if count == 1 {
+//			return singular
+//		}
+//		return plural
+//	})
+//
+// templates can use `{{ items.size | pluralize: 'item', 'items' }}`. The first parameter
+// receives the input, and the following parameters the filter's arguments, which are
+// converted to the parameter types: strings with to_s, numbers like the math filters,
+// bools by truthiness, and slices and string keyed maps element by element. A variadic
+// function accepts any number of trailing arguments.
+//
+// Keyword arguments are passed in a Kwargs map, or a struct embedding Keywords, which
+// must be the last parameter (or the last before a variadic one). Other structs and maps
+// are positional parameters. Struct fields are matched by their `liquid:"name"` tag, or
+// by their name ignoring case and underscores, so
+//
+//	type options struct {
+//		liquid.Keywords
+//		AllowFalse bool
+//	}
+//
+// receives `allow_false: true`. The function returns a value, and optionally an error
+// which is raised by the render. Filters that do I/O can take a context.Context before
+// the input, they're given the render's context, see Template.RenderContext.
+//
+// RegisterFilter panics if fn isn't a function with a signature it can call. Registering
+// an existing name, including the name of a standard filter, replaces that filter. It
+// registers the filter on the default engine, other engines don't see it, see
+// Engine.RegisterFilter
+func RegisterFilter(name string, fn interface{}) {
+	defaultEngine.RegisterFilter(name, fn)
+}
+
+func mustReflectFilter(name string, fn interface{}) contextFilterFunc {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isWordChar(r) }) >= 0 {
+		panic(fmt.Sprintf("liquid: invalid filter name %q", name))
+	}
+
+	filter, err := reflectFilter(name, fn)
+	if err != nil {
+		panic(fmt.Sprintf("liquid: can't register filter %q: %v", name, err))
+	}
+	return filter
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// reflectFilter validates the signature of a filter function and adapts it into a contextFilterFunc
+func reflectFilter(name string, fn interface{}) (contextFilterFunc, error) {
+	value := reflect.ValueOf(fn)
+	if fn == nil || value.Kind() != reflect.Func || value.IsNil() {
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+
+	t := value.Type()
+
+	// an optional context.Context comes before the input
+	first := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		first = 1
+	}
+	if t.NumIn() == first || (t.IsVariadic() && t.NumIn() == first+1) {
+		return nil, fmt.Errorf("%v has no parameter for the input", t)
+	}
+
+	switch {
+	case t.NumOut() == 1 && t.Out(0) != errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+	default:
+		return nil, fmt.Errorf("%v must return a value, or a value and an error", t)
+	}
+
+	// the parameters are the (context and) input, the positional arguments, then
+	// optionally the keyword options and variadic arguments
+	positional := t.NumIn()
+	if t.IsVariadic() {
+		positional--
+	}
+
+	options := -1
+	if last := positional - 1; last > first && isOptionsType(t.In(last)) {
+		options = last
+		positional--
+	}
+
+	var optionFields map[string]int
+	if options >= 0 && t.In(options).Kind() == reflect.Struct {
+		var err error
+		if optionFields, err = structOptionFields(t.In(options)); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := first; i < t.NumIn(); i++ {
+		paramType := t.In(i)
+		if i == options {
+			continue
+		}
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			paramType = paramType.Elem()
+		}
+		if isOptionsType(paramType) {
+			return nil, fmt.Errorf("keyword options %v must be the last parameter after the input", paramType)
+		}
+		if !canCoerce(paramType) {
+			return nil, fmt.Errorf("unsupported parameter type %v", paramType)
+		}
+	}
+
+	return func(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (result interface{}, err error) {
+		// the number of positional arguments after the input
+		required := positional - first - 1
+		maxArgs := required
+		if t.IsVariadic() {
+			maxArgs = -1
+		}
+		if err := checkArgs(args, required, maxArgs); err != nil {
+			return nil, err
+		}
+
+		in := make([]reflect.Value, 0, t.NumIn())
+		if first > 0 {
+			in = append(in, reflect.ValueOf(ctx.Context()))
+		}
+		inputValue, err := coerceArg(input, t.In(first))
+		if err != nil {
+			return nil, err
+		}
+		in = append(in, inputValue)
+
+		for i := first + 1; i < positional; i++ {
+			arg, err := coerceArg(args[i-first-1], t.In(i))
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, arg)
+		}
+
+		if options >= 0 {
+			opts, err := coerceOptions(kwargs, t.In(options), optionFields)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, opts)
+		} else if len(kwargs) > 0 {
+			return nil, unknownKeywords(kwargs)
+		}
+
+		if t.IsVariadic() {
+			elemType := t.In(t.NumIn() - 1).Elem()
+			for _, arg := range args[required:] {
+				v, err := coerceArg(arg, elemType)
+				if err != nil {
+					return nil, err
+				}
+				in = append(in, v)
+			}
+		}
+
+		// a filter which panics raises an ArgumentError rather than taking the render down with it
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, argumentError("filter '%v' panicked: %v", name, r)
+			}
+		}()
+
+		out := value.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	}, nil
+}
+
+// isOptionsType reports whether a parameter receives the keyword arguments, which
+// it does when it's a Kwargs or a struct embedding Keywords
+func isOptionsType(t reflect.Type) bool {
+	if t == kwargsType {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Anonymous && field.Type == keywordsType {
+			return true
+		}
+	}
+	return false
+}
+
+// structOptionFields maps keyword names to the index of the struct field receiving them. Fields
+// are keyed by their liquid tag, or their lowercase name which is matched ignoring underscores
+func structOptionFields(t reflect.Type) (map[string]int, error) {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("liquid")
+		if field.PkgPath != "" || tag == "-" || (field.Anonymous && field.Type == keywordsType) {
+			continue
+		}
+		if !canCoerce(field.Type) {
+			return nil, fmt.Errorf("unsupported option type %v for %v", field.Type, field.Name)
+		}
+
+		key := strings.ToLower(field.Name)
+		if tag != "" {
+			key = tag
+		}
+		fields[key] = i
+	}
+	return fields, nil
+}
+
+func coerceOptions(kwargs map[string]interface{}, t reflect.Type, fields map[string]int) (reflect.Value, error) {
+	if t.Kind() == reflect.Map {
+		opts := reflect.MakeMapWithSize(t, len(kwargs))
+		for k, v := range kwargs {
+			value, err := coerceArg(v, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			opts.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), value)
+		}
+		return opts, nil
+	}
+
+	opts := reflect.New(t).Elem()
+	unknown := map[string]interface{}{}
+	for k, v := range kwargs {
+		i, ok := fields[k]
+		if !ok {
+			i, ok = fields[strings.ToLower(strings.Replace(k, "_", "", -1))]
+		}
+		if !ok {
+			unknown[k] = v
+			continue
+		}
+
+		value, err := coerceArg(v, t.Field(i).Type)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		opts.Field(i).Set(value)
+	}
+
+	if len(unknown) > 0 {
+		return reflect.Value{}, unknownKeywords(unknown)
+	}
+	return opts, nil
+}
+
+func unknownKeywords(kwargs map[string]interface{}) error {
+	names := make([]string, 0, len(kwargs))
+	for k := range kwargs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	noun := "keyword"
+	if len(names) > 1 {
+		noun = "keywords"
+	}
+	return argumentError("unknown %v: %v", noun, strings.Join(names, ", "))
+}
+
+// canCoerce reports whether coerceArg is able to produce values of a type
+func canCoerce(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Interface, reflect.Struct, reflect.Ptr,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return canCoerce(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && canCoerce(t.Elem())
+	}
+	return false
+}
+
+// coerceArg converts an evaluated argument into a value of the parameter's type
+func coerceArg(v interface{}, t reflect.Type) (reflect.Value, error) {
+	if v != nil && reflect.TypeOf(v).AssignableTo(t) {
+		return reflect.ValueOf(v), nil
+	}
+
+	e := interfaceToExpression(v)
+	switch t.Kind() {
+	case reflect.Interface:
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+	case reflect.String:
+		return reflect.ValueOf(toS(v)).Convert(t), nil
+	case reflect.Bool:
+		return reflect.ValueOf(isTruthy(e)).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := coerceInteger(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := coerceInteger(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if i < 0 {
+			return reflect.Value{}, argumentError("%v can't be negative", t)
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(floatOf(toNumber(v))).Convert(t), nil
+	case reflect.Slice:
+		if e == Nil {
+			return reflect.Zero(t), nil
+		}
+		if array, ok := e.(arrayExpr); ok {
+			slice := reflect.MakeSlice(t, len(array), len(array))
+			for i, item := range array {
+				value, err := coerceArg(item, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				slice.Index(i).Set(value)
+			}
+			return slice, nil
+		}
+	case reflect.Map:
+		if e == Nil {
+			return reflect.Zero(t), nil
+		}
+		if hash, ok := e.(hashExpr); ok {
+			m := reflect.MakeMapWithSize(t, len(hash))
+			for k, item := range hash {
+				value, err := coerceArg(item, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), value)
+			}
+			return m, nil
+		}
+	case reflect.Ptr:
+		if e == Nil {
+			return reflect.Zero(t), nil
+		}
+	}
+
+	class := rubyClass(e)
+	switch e.(type) {
+	case objectExpr, dropExpr:
+		class = fmt.Sprintf("%T", v)
+	}
+	return reflect.Value{}, argumentError("cannot convert %v into %v", class, t)
+}
+
+// coerceInteger converts a value for an integer parameter, floats are truncated as with ruby's Integer()
+func coerceInteger(v interface{}) (int, error) {
+	if f, ok := interfaceToExpression(v).(floatExpr); ok {
+		return int(f), nil
+	}
+	return toInteger(v)
+}
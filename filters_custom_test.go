@@ -0,0 +1,217 @@
+package liquid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func unregisterFilter(name string) {
+	defaultEngine.mu.Lock()
+	defer defaultEngine.mu.Unlock()
+	delete(defaultEngine.filters, name)
+}
+
+// checkRenderError checks that rendering a template fails with the given message
+func checkRenderError(t *testing.T, tpl *Template, vars Vars, want string) {
+	if _, err := tpl.Render(vars); err == nil || err.Error() != want {
+		t.Errorf("want error: %v, got: %v", want, err)
+	}
+}
+
+type moneyOptions struct {
+	Keywords
+	Currency   string
+	WithSymbol bool `liquid:"symbol"`
+	Precision  int
+	internal   string
+}
+
+func TestRegisterFilter(t *testing.T) {
+	RegisterFilter("pluralize", func(count int, singular, plural string) string {
+		if count == 1 {
+			return singular
+		}
+		return plural
+	})
+	defer unregisterFilter("pluralize")
+
+	RegisterFilter("money", func(cents float64, opts moneyOptions) (string, error) {
+		if opts.Currency == "" {
+			return "", errors.New("missing currency")
+		}
+		s := fmt.Sprintf("%.*f %v", opts.Precision, cents/100, opts.Currency)
+		if opts.WithSymbol {
+			s = "$" + s
+		}
+		return s, nil
+	})
+	defer unregisterFilter("money")
+
+	RegisterFilter("wrap", func(input interface{}, parts ...interface{}) (interface{}, error) {
+		var s []string
+		for _, part := range parts {
+			s = append(s, toS(part))
+		}
+		return strings.Join(s, toS(input)), nil
+	})
+	defer unregisterFilter("wrap")
+
+	RegisterFilter("total", func(items []float64, attrs Kwargs) float64 {
+		var total float64
+		for _, item := range items {
+			total += item
+		}
+		if attrs["double"] == "true" {
+			total *= 2
+		}
+		return total
+	})
+	defer unregisterFilter("total")
+
+	// structs and maps without the markers are positional arguments
+	RegisterFilter("days_between", func(from, to time.Time, names map[string]string) string {
+		return fmt.Sprintf("%v %v", to.Sub(from).Hours()/24, names["unit"])
+	})
+	defer unregisterFilter("days_between")
+
+	vars := Vars{"n": 1, "items": []interface{}{"1.5", 2, 3.5}}
+	checkTemplateRender(t, `{{ n | pluralize: 'item', 'items' }}`, vars, "item")
+	checkTemplateRender(t, `{{ items.size | pluralize: 'item', 'items' }}`, vars, "items")
+	checkTemplateRender(t, `{{ '3' | pluralize: 1, 2 }}`, vars, "2")
+	checkTemplateRender(t, `{{ 1999 | money: currency: 'CAD' }}`, vars, "20 CAD")
+	checkTemplateRender(t, `{{ '1999' | money: currency: 'CAD', symbol: true, precision: 2 }}`, vars, "$19.99 CAD")
+	checkTemplateRender(t, `{{ '-' | wrap: 'a', 1, 2.5 }}`, vars, "a-1-2.5")
+	checkTemplateRender(t, `{{ '-' | wrap }}`, vars, "")
+	checkTemplateRender(t, `{{ items | total }}`, vars, "7.0")
+	checkTemplateRender(t, `{{ items | total: double: 'true' }}`, vars, "14.0")
+
+	times := Vars{
+		"from":  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"to":    time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC),
+		"names": map[string]interface{}{"unit": "days"},
+	}
+	checkTemplateRender(t, `{{ from | days_between: to, names }}`, times, "2 days")
+
+	tpl, _ := ParseTemplate(`{{ n | pluralize: 'item' }}`)
+	checkRenderError(t, tpl, vars, "line 1, column 1: Liquid error: wrong number of arguments (given 2, expected 3)")
+
+	tpl, _ = ParseTemplate(`{{ n | pluralize: 'a', 'b', foo: 1 }}`)
+	checkRenderError(t, tpl, vars, "line 1, column 1: Liquid error: unknown keyword: foo")
+
+	tpl, _ = ParseTemplate(`{{ 1 | money: currency: 'CAD', rate: 1, fee: 2 }}`)
+	checkRenderError(t, tpl, vars, "line 1, column 1: Liquid error: unknown keywords: fee, rate")
+
+	// tagged fields are only matched by their tag
+	tpl, _ = ParseTemplate(`{{ 1 | money: currency: 'CAD', with_symbol: true }}`)
+	checkRenderError(t, tpl, vars, "line 1, column 1: Liquid error: unknown keyword: with_symbol")
+
+	tpl, _ = ParseTemplate(`{{ 1 | money }}`)
+	checkRenderError(t, tpl, vars, "line 1, column 1: missing currency")
+
+	tpl, _ = ParseTemplate(`{{ n | pluralize: 'a', 'b' }}`)
+	checkRenderError(t, tpl, Vars{"n": "x"}, "line 1, column 1: Liquid error: invalid integer")
+}
+
+func TestRegisterFilterPanics(t *testing.T) {
+	RegisterFilter("explode", func(input []interface{}, i int) interface{} {
+		return input[i]
+	})
+	defer unregisterFilter("explode")
+
+	// filters which panic raise an error, which the error policy handles
+	tpl, _ := ParseTemplate(`a{{ items | explode: 5 }}b`)
+	vars := Vars{"items": []interface{}{1}}
+	if _, err := tpl.Render(vars); !errors.Is(err, ArgumentError{}) {
+		t.Errorf("want an ArgumentError, got: %v", err)
+	}
+	got, err := tpl.Render(vars, WithInlineErrors())
+	if want := "aLiquid error (line 1): filter 'explode' panicked: runtime error: index out of range [5] with length 1b"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+}
+
+func TestRegisterFilterOverridesStandardFilters(t *testing.T) {
+	RegisterFilter("upcase", func(input string) string {
+		return "UP " + input
+	})
+	defer unregisterFilter("upcase")
+
+	checkTemplateRender(t, `{{ 'a' | upcase }}`, nil, "UP a")
+}
+
+func TestRegisterFilterValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"", func(string) string { return "" }},
+		{"bad name", func(string) string { return "" }},
+		{"nil", nil},
+		{"not_a_func", "upcase"},
+		{"no_input", func() string { return "" }},
+		{"only_variadic", func(...string) string { return "" }},
+		{"no_result", func(string) {}},
+		{"only_error", func(string) error { return nil }},
+		{"too_many_results", func(string) (string, string, error) { return "", "", nil }},
+		{"second_not_error", func(string) (string, string) { return "", "" }},
+		{"bad_param", func(string, chan int) string { return "" }},
+		{"bad_option", func(string, struct {
+			Keywords
+			C chan int
+		}) string {
+			return ""
+		}},
+		{"options_input", func(Kwargs) string { return "" }},
+		{"options_not_last", func(string, Kwargs, string) string { return "" }},
+		{"two_options", func(string, moneyOptions, Kwargs) string { return "" }},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterFilter(%q, %T) should have panicked", test.name, test.fn)
+					unregisterFilter(test.name)
+				}
+			}()
+			RegisterFilter(test.name, test.fn)
+		}()
+	}
+}
+
+func TestEngineFilters(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterFilter("shout", func(input string) string {
+		return strings.ToUpper(input) + "!"
+	})
+	other := NewEngine()
+
+	tpl, err := engine.ParseTemplate(`{{ 'hi' | shout }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(nil); err != nil || got != "HI!" {
+		t.Errorf("want: %q, got: %q (%v)", "HI!", got, err)
+	}
+
+	// other engines, including the default one, don't see the filter
+	for _, parse := range []func(string, ...Option) (*Template, error){other.ParseTemplate, ParseTemplate} {
+		tpl, err := parse(`{{ 'hi' | shout }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := tpl.Render(nil); got != "hi" {
+			t.Errorf("filter leaked out of its engine, got: %q", got)
+		}
+	}
+
+	// filters registered on the default engine don't reach other engines
+	RegisterFilter("shout", func(input string) string { return "global" })
+	defer unregisterFilter("shout")
+	if got, _ := tpl.Render(nil); got != "HI!" {
+		t.Errorf("want: %q, got: %q", "HI!", got)
+	}
+}
@@ -0,0 +1,513 @@
+package liquid
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Date filters. Dates are formatted with ruby's strftime directives, and the
+// current time and time zone come from the render options
+
+var digitsRegexp = regexp.MustCompile(`\A\d+\z`)
+
+// dateLayouts are the formats accepted for date strings, standing in for ruby's Time.parse
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+	"Monday, January 2, 2006",
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// location is the time zone the render uses for dates
+func (c *Context) location() *time.Location {
+	if c.options.location != nil {
+		return c.options.location
+	}
+	return time.Local
+}
+
+// now is the current time in the render's time zone
+func (c *Context) now() time.Time {
+	now := time.Now
+	if c.options.now != nil {
+		now = c.options.now
+	}
+	return now().In(c.location())
+}
+
+// toDate is the analog to Liquid::Utils.to_date. It accepts times, unix timestamps as integers or
+// strings, "now" and "today", and the dateLayouts. ok is false if the input isn't a date
+func toDate(ctx *Context, input interface{}) (date time.Time, ok bool) {
+	switch input.(type) {
+	case time.Time:
+		date = input.(time.Time)
+		if ctx.options.location != nil {
+			date = date.In(ctx.options.location)
+		}
+		return date, true
+	case *time.Time:
+		if input.(*time.Time) == nil {
+			return date, false
+		}
+		return toDate(ctx, *input.(*time.Time))
+	}
+
+	switch e := interfaceToExpression(input); e.(type) {
+	case integerExpr:
+		return time.Unix(int64(e.(integerExpr)), 0).In(ctx.location()), true
+	case stringExpr:
+		s := strings.TrimSpace(string(e.(stringExpr)))
+		switch {
+		case s == "":
+			return date, false
+		case strings.EqualFold(s, "now"), strings.EqualFold(s, "today"):
+			return ctx.now(), true
+		case digitsRegexp.MatchString(s):
+			seconds, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return date, false
+			}
+			return time.Unix(seconds, 0).In(ctx.location()), true
+		}
+
+		for _, layout := range dateLayouts {
+			if date, err := time.ParseInLocation(layout, s, ctx.location()); err == nil {
+				return date, true
+			}
+		}
+	}
+	return date, false
+}
+
+// date formats a date with ruby's strftime directives. Inputs which aren't
+// dates, or an empty format, return the input unchanged
+//
+// {{ order.created_at | date: '%b %-d, %Y' }} #=> 'Mar 7, 2024'
+// {{ 'now' | date: '%Y' }}
+func dateFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	format := toS(args[0])
+	if format == "" {
+		return input, nil
+	}
+
+	date, ok := toDate(ctx, input)
+	if !ok {
+		return input, nil
+	}
+	return strftime(date, format)
+}
+
+// date_add adds an amount of seconds, minutes, hours, days, weeks, months or
+// years to a date. Negative amounts subtract
+//
+// {{ order.created_at | date_add: 30, 'days' | date: '%F' }}
+func dateAddFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	amount, err := toInteger(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	date, ok := toDate(ctx, input)
+	if !ok {
+		return input, nil
+	}
+
+	switch unit := toS(args[1]); strings.TrimSuffix(unit, "s") {
+	case "second":
+		return date.Add(time.Duration(amount) * time.Second), nil
+	case "minute":
+		return date.Add(time.Duration(amount) * time.Minute), nil
+	case "hour":
+		return date.Add(time.Duration(amount) * time.Hour), nil
+	case "day":
+		return date.AddDate(0, 0, amount), nil
+	case "week":
+		return date.AddDate(0, 0, amount*7), nil
+	case "month":
+		return date.AddDate(0, amount, 0), nil
+	case "year":
+		return date.AddDate(amount, 0, 0), nil
+	default:
+		return nil, argumentError("invalid date unit '%v'", unit)
+	}
+}
+
+// time_ago describes the time between a date and now, or the date given as
+// the argument, in words like ruby on rails' time_ago_in_words
+//
+// {{ comment.created_at | time_ago }} #=> 'about 2 hours ago'
+// {{ sale.ends_at | time_ago }} #=> 'in 3 days'
+func timeAgoFilter(ctx *Context, input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	date, ok := toDate(ctx, input)
+	if !ok {
+		return input, nil
+	}
+
+	now := ctx.now()
+	if len(args) > 0 {
+		if now, ok = toDate(ctx, args[0]); !ok {
+			return nil, argumentError("invalid date")
+		}
+	}
+
+	distance := now.Sub(date)
+	if distance < 0 {
+		return "in " + distanceInWords(-distance), nil
+	}
+	return distanceInWords(distance) + " ago", nil
+}
+
+// distanceInWords is the analog to rails' distance_of_time_in_words
+func distanceInWords(d time.Duration) string {
+	const (
+		day   = 1440
+		month = 30 * day
+		year  = 365 * day
+	)
+
+	minutes := int(math.Round(d.Minutes()))
+	switch {
+	case minutes < 1:
+		return "less than a minute"
+	case minutes < 2:
+		return "1 minute"
+	case minutes < 45:
+		return fmt.Sprintf("%v minutes", minutes)
+	case minutes < 90:
+		return "about 1 hour"
+	case minutes < day:
+		return fmt.Sprintf("about %v hours", int(math.Round(float64(minutes)/60)))
+	case minutes < 42*60:
+		return "1 day"
+	case minutes < month:
+		return fmt.Sprintf("%v days", int(math.Round(float64(minutes)/day)))
+	case minutes < 2*month:
+		return "about 1 month"
+	case minutes < year:
+		return fmt.Sprintf("%v months", int(math.Round(float64(minutes)/month)))
+	}
+
+	years, remainder := minutes/year, minutes%year
+	switch {
+	case remainder < year/4:
+		return pluralize(years, "about %v year")
+	case remainder < year*3/4:
+		return pluralize(years, "over %v year")
+	}
+	return pluralize(years+1, "almost %v year")
+}
+
+func pluralize(count int, format string) string {
+	s := fmt.Sprintf(format, count)
+	if count != 1 {
+		s += "s"
+	}
+	return s
+}
+
+// strftime formats a time with ruby's Time#strftime directives. A directive can be
+// preceded by flags (- no padding, _ pad with spaces, 0 pad with zeros, ^ upcase,
+// # change case), a minimum width, and for %z colons to separate the offset. Widths
+// over maxStrftimeWidth raise an ArgumentError, as ruby refuses to build huge results
+func strftime(t time.Time, format string) (string, error) {
+	var output bytes.Buffer
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			output.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		var flags string
+		for i+1 < len(format) && strings.IndexByte("-_0^#:", format[i+1]) >= 0 {
+			i++
+			flags += string(format[i])
+		}
+
+		width := -1
+		for i+1 < len(format) && isDigit(format[i+1]) {
+			i++
+			if width < 0 {
+				width = 0
+			}
+			if width = width*10 + int(format[i]-'0'); width > maxStrftimeWidth {
+				return "", argumentError("strftime width too large in '%v'", format)
+			}
+		}
+
+		if i+1 >= len(format) {
+			output.WriteString(format[start:])
+			break
+		}
+		i++
+
+		if s, ok := strftimeDirective(t, format[i], flags, width); ok {
+			output.WriteString(s)
+		} else {
+			output.WriteString(format[start : i+1])
+		}
+	}
+
+	return output.String(), nil
+}
+
+// maxStrftimeWidth is the widest a directive can be padded to
+const maxStrftimeWidth = 1024
+
+// strftimeDirective formats a single conversion, ok is false if it isn't recognized
+func strftimeDirective(t time.Time, conversion byte, flags string, width int) (string, bool) {
+	number := func(n, defaultWidth int, defaultPad byte) (string, bool) {
+		return padNumber(n, flags, width, defaultWidth, defaultPad), true
+	}
+	text := func(s string, swapUpper bool) (string, bool) {
+		return padText(s, flags, width, swapUpper), true
+	}
+	// the composite formats have no widths, so they can't fail
+	composite := func(format string) (string, bool) {
+		s, _ := strftime(t, format)
+		return padText(s, flags, width, true), true
+	}
+
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+
+	switch conversion {
+	case 'Y':
+		return number(t.Year(), 4, '0')
+	case 'C':
+		return number(floorDiv(t.Year(), 100), 2, '0')
+	case 'y':
+		return number(floorMod(t.Year(), 100), 2, '0')
+	case 'm':
+		return number(int(t.Month()), 2, '0')
+	case 'B':
+		return text(t.Month().String(), true)
+	case 'b', 'h':
+		return text(t.Month().String()[:3], true)
+	case 'd':
+		return number(t.Day(), 2, '0')
+	case 'e':
+		return number(t.Day(), 2, ' ')
+	case 'j':
+		return number(t.YearDay(), 3, '0')
+	case 'H':
+		return number(t.Hour(), 2, '0')
+	case 'k':
+		return number(t.Hour(), 2, ' ')
+	case 'I':
+		return number(hour12, 2, '0')
+	case 'l':
+		return number(hour12, 2, ' ')
+	case 'P':
+		return text(strings.ToLower(meridian(t)), false)
+	case 'p':
+		if strings.Contains(flags, "#") {
+			return padText(strings.ToLower(meridian(t)), strings.Replace(flags, "#", "", -1), width, false), true
+		}
+		return text(meridian(t), false)
+	case 'M':
+		return number(t.Minute(), 2, '0')
+	case 'S':
+		return number(t.Second(), 2, '0')
+	case 'L', 'N':
+		digits := 3
+		if conversion == 'N' {
+			digits = 9
+		}
+		if width > 0 {
+			digits = width
+		}
+		fraction := fmt.Sprintf("%09d", t.Nanosecond())
+		for len(fraction) < digits {
+			fraction += "0"
+		}
+		return fraction[:digits], true
+	case 'z':
+		return padText(zoneOffset(t, strings.Count(flags, ":")), strings.Replace(flags, ":", "", -1), width, false), true
+	case 'Z':
+		zone, _ := t.Zone()
+		return text(zone, true)
+	case 'A':
+		return text(t.Weekday().String(), true)
+	case 'a':
+		return text(t.Weekday().String()[:3], true)
+	case 'u':
+		return number((int(t.Weekday())+6)%7+1, 1, '0')
+	case 'w':
+		return number(int(t.Weekday()), 1, '0')
+	case 'G':
+		year, _ := t.ISOWeek()
+		return number(year, 4, '0')
+	case 'g':
+		year, _ := t.ISOWeek()
+		return number(floorMod(year, 100), 2, '0')
+	case 'V':
+		_, week := t.ISOWeek()
+		return number(week, 2, '0')
+	case 'U':
+		return number((t.YearDay()+6-int(t.Weekday()))/7, 2, '0')
+	case 'W':
+		return number((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2, '0')
+	case 's':
+		return number(int(t.Unix()), 1, '0')
+	case 'Q':
+		return number(int(t.UnixNano()/int64(time.Millisecond)), 1, '0')
+	case 'n':
+		return "\n", true
+	case 't':
+		return "\t", true
+	case '%':
+		return "%", true
+	case 'c':
+		return composite("%a %b %e %H:%M:%S %Y")
+	case 'D', 'x':
+		return composite("%m/%d/%y")
+	case 'F':
+		return composite("%Y-%m-%d")
+	case 'T', 'X':
+		return composite("%H:%M:%S")
+	case 'R':
+		return composite("%H:%M")
+	case 'r':
+		return composite("%I:%M:%S %p")
+	case '+':
+		return composite("%a %b %e %H:%M:%S %Z %Y")
+	case 'v':
+		return composite("%e-%^b-%4Y")
+	}
+	return "", false
+}
+
+func meridian(t time.Time) string {
+	if t.Hour() < 12 {
+		return "AM"
+	}
+	return "PM"
+}
+
+// zoneOffset formats the offset from UTC as +hhmm, or with colons as +hh:mm or +hh:mm:ss
+func zoneOffset(t time.Time, colons int) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+
+	hours, minutes, seconds := offset/3600, offset/60%60, offset%60
+	switch colons {
+	case 0:
+		return fmt.Sprintf("%c%02d%02d", sign, hours, minutes)
+	case 1:
+		return fmt.Sprintf("%c%02d:%02d", sign, hours, minutes)
+	}
+	return fmt.Sprintf("%c%02d:%02d:%02d", sign, hours, minutes, seconds)
+}
+
+// padNumber pads a number to the width, or the directive's default width and padding
+func padNumber(n int, flags string, width, defaultWidth int, pad byte) string {
+	if width < 0 {
+		width = defaultWidth
+	}
+
+	switch {
+	case strings.Contains(flags, "-"):
+		return strconv.Itoa(n)
+	case strings.Contains(flags, "_"):
+		pad = ' '
+	case strings.Contains(flags, "0"):
+		pad = '0'
+	}
+
+	digits := strconv.Itoa(abs(n))
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		width--
+	}
+
+	padding := ""
+	if width > len(digits) {
+		padding = strings.Repeat(string(pad), width-len(digits))
+	}
+	if pad == ' ' {
+		return padding + sign + digits
+	}
+	return sign + padding + digits
+}
+
+// padText applies the case flags and pads text to the width, with spaces
+// unless the 0 flag is given. swapUpper is true for directives where the #
+// flag upcases, as with ruby
+func padText(s, flags string, width int, swapUpper bool) string {
+	switch {
+	case strings.Contains(flags, "^"):
+		s = strings.ToUpper(s)
+	case strings.Contains(flags, "#") && swapUpper:
+		s = strings.ToUpper(s)
+	case strings.Contains(flags, "#"):
+		s = strings.Map(swapCase, s)
+	}
+
+	if strings.Contains(flags, "-") || width <= len(s) {
+		return s
+	}
+
+	pad := " "
+	if strings.Contains(flags, "0") {
+		pad = "0"
+	}
+	return strings.Repeat(pad, width-len(s)) + s
+}
+
+func swapCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
@@ -0,0 +1,185 @@
+package liquid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// date filters from integration/standard_filter_test.rb
+
+var testNow = time.Date(2024, time.March, 7, 14, 5, 9, 123456789, time.UTC)
+
+// checkDateRender renders a template in UTC with the current time fixed at testNow
+func checkDateRender(t *testing.T, template string, vars Vars, want string, opts ...Option) {
+	tpl, err := ParseTemplate(template)
+	if err != nil {
+		t.Errorf("Couldn't parse the template: %v", err)
+		return
+	}
+
+	opts = append([]Option{WithTimeZone(time.UTC), WithNow(func() time.Time { return testNow })}, opts...)
+	if got, err := tpl.Render(vars, opts...); err != nil {
+		t.Errorf("%v rendering error: %v", template, err)
+	} else if got != want {
+		t.Errorf("%v want: %q, got: %q", template, want, got)
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	date := time.Date(2006, time.May, 3, 9, 7, 2, 45000000, time.FixedZone("EST", -5*3600))
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%Y-%m-%d %H:%M:%S", "2006-05-03 09:07:02"},
+		{"%B %d %Y", "May 03 2006"},
+		{"%b %-d, %Y", "May 3, 2006"},
+		{"%A %a %^a %#A", "Wednesday Wed WED WEDNESDAY"},
+		{"%e|%_m|%-m|%3d", " 3| 5|5|003"},
+		{"%j %y %C", "123 06 20"},
+		{"%I:%M %p %P %#p", "09:07 AM am am"},
+		{"%l %k", " 9  9"},
+		{"%L %N %3N", "045 045000000 045"},
+		{"%z %:z %::z %Z", "-0500 -05:00 -05:00:00 EST"},
+		{"%s", "1146665222"},
+		{"%u %w %U %W %V %G", "3 3 18 18 18 2006"},
+		{"%D %F %T %R %r", "05/03/06 2006-05-03 09:07:02 09:07 09:07:02 AM"},
+		{"%c", "Wed May  3 09:07:02 2006"},
+		{"%%Y %n%t", "%Y \n\t"},
+		{"%10A|%-10A|%010d", " Wednesday|Wednesday|0000000003"},
+		{"%q %", "%q %"},
+		{"%1024d", strings.Repeat("0", 1022) + "03"},
+	}
+
+	for _, test := range tests {
+		if got, err := strftime(date, test.format); err != nil || got != test.want {
+			t.Errorf("strftime(%q) want: %q, got: %q (%v)", test.format, test.want, got, err)
+		}
+	}
+
+	// huge widths are refused before they're padded
+	for _, format := range []string{"%1025Y", "%1000000000Y", "%99999999999999999999999N"} {
+		if _, err := strftime(date, format); !errors.Is(err, ArgumentError{}) {
+			t.Errorf("strftime(%q) want an ArgumentError, got: %v", format, err)
+		}
+	}
+	tpl, _ := ParseTemplate(`{{ 1 | date: "%1000000000Y" }}`)
+	checkRenderError(t, tpl, nil, `line 1, column 1: Liquid error: strftime width too large in '%1000000000Y'`)
+}
+
+func TestDate(t *testing.T) {
+	vars := Vars{
+		"time":      time.Date(2006, time.May, 3, 10, 0, 0, 0, time.UTC),
+		"timestamp": 1152098955,
+		"empty":     "",
+	}
+
+	checkDateRender(t, `{{ time | date: '%B' }}`, vars, "May")
+	checkDateRender(t, `{{ time | date: '%m/%d/%Y' }}`, vars, "05/03/2006")
+	checkDateRender(t, `{{ '2006-05-05 10:00:00' | date: '%B' }}`, vars, "May")
+	checkDateRender(t, `{{ '2006-07-05T10:00:00Z' | date: '%Y-%m-%d %H:%M' }}`, vars, "2006-07-05 10:00")
+	checkDateRender(t, `{{ 'Mon, 2 Jan 2006 15:04:05 -0700' | date: '%H %z' }}`, vars, "15 -0700")
+	checkDateRender(t, `{{ 'January 5, 2006' | date: '%d' }}`, vars, "05")
+	checkDateRender(t, `{{ timestamp | date: '%m/%d/%Y' }}`, vars, "07/05/2006")
+	checkDateRender(t, `{{ '1152098955' | date: '%m/%d/%Y' }}`, vars, "07/05/2006")
+	checkDateRender(t, `{{ 'now' | date: '%Y-%m-%d %H:%M' }}`, vars, "2024-03-07 14:05")
+	checkDateRender(t, `{{ 'Today' | date: '%Y' }}`, vars, "2024")
+	checkDateRender(t, `{{ time | date: '' }}`, vars, "2006-05-03 10:00:00 +0000")
+	checkDateRender(t, `{{ empty | date: '%B' }}`, vars, "")
+	checkDateRender(t, `{{ nil | date: '%B' }}`, vars, "")
+	checkDateRender(t, `{{ 'not a date' | date: '%B' }}`, vars, "not a date")
+	checkDateRender(t, `{{ 1.5 | date: '%B' }}`, vars, "1.5")
+}
+
+func TestDateTimeZone(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	vars := Vars{"time": time.Date(2006, time.May, 3, 20, 0, 0, 0, time.UTC)}
+
+	checkDateRender(t, `{{ time | date: '%d %H:%M %Z' }}`, vars, "04 05:00 JST", WithTimeZone(tokyo))
+	checkDateRender(t, `{{ 0 | date: '%F %H:%M %z' }}`, vars, "1970-01-01 09:00 +0900", WithTimeZone(tokyo))
+	checkDateRender(t, `{{ '2006-05-03 10:00' | date: '%H:%M %z' }}`, vars, "10:00 +0900", WithTimeZone(tokyo))
+	checkDateRender(t, `{{ 'now' | date: '%H:%M' }}`, vars, "23:05", WithTimeZone(tokyo))
+
+	engine := NewEngine(WithTimeZone(tokyo))
+	tpl, err := engine.ParseTemplate(`{{ 0 | date: '%H:%M' }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(nil); err != nil || got != "09:00" {
+		t.Errorf("want: %q, got: %q (%v)", "09:00", got, err)
+	}
+	if got, err := tpl.Render(nil, WithTimeZone(time.UTC)); err != nil || got != "00:00" {
+		t.Errorf("want: %q, got: %q (%v)", "00:00", got, err)
+	}
+
+	// without a time zone times keep their own
+	tpl, err = ParseTemplate(`{{ time | date: '%H:%M %Z' }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tpl.Render(Vars{"time": time.Date(2006, time.May, 3, 20, 0, 0, 0, tokyo)})
+	if err != nil || got != "20:00 JST" {
+		t.Errorf("want: %q, got: %q (%v)", "20:00 JST", got, err)
+	}
+}
+
+func TestWithNow(t *testing.T) {
+	engine := NewEngine(WithTimeZone(time.UTC), WithNow(func() time.Time { return testNow }))
+	source := `{{ 'now' | date: '%F %T' }} {{ 'today' | date_add: 1, 'day' | date: '%F' }} {{ '2024-03-01' | time_ago }}`
+	if got, err := engine.Render(source, nil); err != nil || got != "2024-03-07 14:05:09 2024-03-08 7 days ago" {
+		t.Errorf("want: %q, got: %q (%v)", "2024-03-07 14:05:09 2024-03-08 7 days ago", got, err)
+	}
+
+	// options passed to Render override the engine's clock
+	tpl, _ := engine.ParseTemplate(`{{ 'now' | date: '%F' }}`)
+	got, err := tpl.Render(nil, WithNow(func() time.Time { return time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC) }))
+	if err != nil || got != "2000-01-02" {
+		t.Errorf("want: %q, got: %q (%v)", "2000-01-02", got, err)
+	}
+}
+
+func TestDateAdd(t *testing.T) {
+	vars := Vars{"time": time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)}
+
+	checkDateRender(t, `{{ time | date_add: 1, 'day' | date: '%F' }}`, vars, "2024-02-01")
+	checkDateRender(t, `{{ time | date_add: -2, 'weeks' | date: '%F' }}`, vars, "2024-01-17")
+	checkDateRender(t, `{{ time | date_add: 90, 'minutes' | date: '%H:%M' }}`, vars, "13:30")
+	checkDateRender(t, `{{ time | date_add: 1, 'year' | date: '%Y' }}`, vars, "2025")
+	checkDateRender(t, `{{ 'now' | date_add: '3', 'months' | date: '%F' }}`, vars, "2024-06-07")
+	checkDateRender(t, `{{ 'foo' | date_add: 1, 'day' }}`, vars, "foo")
+
+	tpl, _ := ParseTemplate(`{{ time | date_add: 1, 'fortnight' }}`)
+	if _, err := tpl.Render(vars); err == nil || err.Error() != "line 1, column 1: Liquid error: invalid date unit 'fortnight'" {
+		t.Errorf("expected an invalid unit error, got: %v", err)
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{10 * time.Second, "less than a minute ago"},
+		{time.Minute, "1 minute ago"},
+		{20 * time.Minute, "20 minutes ago"},
+		{50 * time.Minute, "about 1 hour ago"},
+		{5 * time.Hour, "about 5 hours ago"},
+		{30 * time.Hour, "1 day ago"},
+		{4 * 24 * time.Hour, "4 days ago"},
+		{40 * 24 * time.Hour, "about 1 month ago"},
+		{100 * 24 * time.Hour, "3 months ago"},
+		{400 * 24 * time.Hour, "about 1 year ago"},
+		{600 * 24 * time.Hour, "over 1 year ago"},
+		{700 * 24 * time.Hour, "almost 2 years ago"},
+		{-3 * 24 * time.Hour, "in 3 days"},
+	}
+
+	for _, test := range tests {
+		checkDateRender(t, `{{ time | time_ago }}`, Vars{"time": testNow.Add(-test.ago)}, test.want)
+	}
+
+	checkDateRender(t, `{{ '2024-01-01' | time_ago: '2024-01-03' }}`, nil, "2 days ago")
+}
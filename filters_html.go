@@ -0,0 +1,146 @@
+package liquid
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTML, URL and base64 filters from Liquid::StandardFilters
+
+var (
+	htmlEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		">", "&gt;",
+		"<", "&lt;",
+		`"`, "&quot;",
+		"'", "&#39;",
+	)
+
+	// htmlEntityRegexp matches the entities escape_once leaves alone, as ruby's
+	// HTML_ESCAPE_ONCE_REGEXP does with a negative lookahead
+	htmlEntityRegexp = regexp.MustCompile(`\A&(?:[a-zA-Z]+|#\d+);`)
+
+	stripHTMLBlocksRegexp = regexp.MustCompile(`(?s)<script.*?</script>|<!--.*?-->|<style.*?</style>`)
+	stripHTMLTagsRegexp   = regexp.MustCompile(`(?s)<.*?>`)
+	newlineRegexp         = regexp.MustCompile(`\r?\n`)
+	percentEncodedRegexp  = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+)
+
+// escape is the analog to ruby's CGI.escapeHTML
+//
+// {{ '<p>love & peace</p>' | escape }} #=> '&lt;p&gt;love &amp; peace&lt;/p&gt;'
+func escapeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, nil
+	}
+	return htmlEscaper.Replace(toS(input)), nil
+}
+
+// escape_once escapes html, without escaping the ampersands of existing entities
+//
+// {{ '1 &lt; 2 & 3' | escape_once }} #=> '1 &lt; 2 &amp; 3'
+var escapeOnceFilter = stringFilter(0, func(input string, args []string) string {
+	var output bytes.Buffer
+	for i := 0; i < len(input); i++ {
+		if input[i] == '&' && htmlEntityRegexp.MatchString(input[i:]) {
+			output.WriteByte('&')
+			continue
+		}
+		output.WriteString(htmlEscaper.Replace(input[i : i+1]))
+	}
+	return output.String()
+})
+
+// url_encode is the analog to ruby's CGI.escape, spaces become + and anything
+// but letters, digits and -_.~ are percent encoded
+//
+// {{ 'john@liquid.com' | url_encode }} #=> 'john%40liquid.com'
+func urlEncodeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, nil
+	}
+	return url.QueryEscape(toS(input)), nil
+}
+
+// url_decode is the analog to ruby's CGI.unescape. Unlike url.QueryUnescape, malformed
+// percent encodings are left as they are, but the result must be valid UTF-8
+//
+// {{ '%27Stop%21%27+said+Fred' | url_decode }} #=> "'Stop!' said Fred"
+func urlDecodeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, nil
+	}
+
+	output := percentEncodedRegexp.ReplaceAllStringFunc(strings.Replace(toS(input), "+", " ", -1), func(s string) string {
+		decoded, _ := url.PathUnescape(s)
+		return decoded
+	})
+	if !utf8.ValidString(output) {
+		return nil, argumentError("invalid byte sequence in UTF-8")
+	}
+	return output, nil
+}
+
+// strip_html removes html tags, along with the contents of scripts, styles and comments
+var stripHTMLFilter = stringFilter(0, func(input string, args []string) string {
+	return stripHTMLTagsRegexp.ReplaceAllString(stripHTMLBlocksRegexp.ReplaceAllString(input, ""), "")
+})
+
+// newline_to_br adds a <br /> tag in front of each newline
+var newlineToBrFilter = stringFilter(0, func(input string, args []string) string {
+	return newlineRegexp.ReplaceAllString(input, "<br />\n")
+})
+
+var (
+	// {{ 'one two three' | base64_encode }} #=> 'b25lIHR3byB0aHJlZQ=='
+	base64EncodeFilter = stringFilter(0, func(input string, args []string) string {
+		return base64.StdEncoding.EncodeToString([]byte(input))
+	})
+
+	base64URLSafeEncodeFilter = stringFilter(0, func(input string, args []string) string {
+		return base64.URLEncoding.EncodeToString([]byte(input))
+	})
+)
+
+// base64_decode is the analog to ruby's Base64.strict_decode64, which
+// unlike the encoding package doesn't allow newlines in the input
+func base64DecodeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return base64Decode("base64_decode", base64.StdEncoding, toS(input))
+}
+
+// base64_url_safe_decode is the analog to ruby's Base64.urlsafe_decode64, the padding is optional
+func base64URLSafeDecodeFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	s := toS(input)
+	if !strings.HasSuffix(s, "=") && len(s)%4 != 0 {
+		s += strings.Repeat("=", 4-len(s)%4)
+	}
+	return base64Decode("base64_url_safe_decode", base64.URLEncoding, s)
+}
+
+func base64Decode(filter string, encoding *base64.Encoding, s string) (interface{}, error) {
+	decoded, err := encoding.Strict().DecodeString(s)
+	if err != nil || strings.ContainsAny(s, "\r\n") {
+		return nil, argumentError("invalid base64 provided to %v", filter)
+	}
+	return string(decoded), nil
+}
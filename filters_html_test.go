@@ -0,0 +1,82 @@
+package liquid
+
+import "testing"
+
+// html filters from integration/standard_filter_test.rb
+
+func TestEscape(t *testing.T) {
+	checkFilter(t, "escape", "<strong>", nil, nil, "&lt;strong&gt;")
+	checkFilter(t, "escape", `"it's" & more`, nil, nil, "&quot;it&#39;s&quot; &amp; more")
+	checkFilter(t, "escape", 1, nil, nil, "1")
+	checkFilter(t, "escape", nil, nil, nil, nil)
+	checkFilter(t, "h", "<strong>", nil, nil, "&lt;strong&gt;")
+}
+
+func TestEscapeOnce(t *testing.T) {
+	checkFilter(t, "escape_once", "&lt;strong&gt;Hulk</strong>", nil, nil, "&lt;strong&gt;Hulk&lt;/strong&gt;")
+	checkFilter(t, "escape_once", "1 &lt; 2 & 3 &#39; &amp &#x27;", nil, nil, "1 &lt; 2 &amp; 3 &#39; &amp;amp &amp;#x27;")
+	checkFilter(t, "escape_once", "café <b>", nil, nil, "café &lt;b&gt;")
+	checkFilter(t, "escape_once", nil, nil, nil, "")
+}
+
+func TestURLEncode(t *testing.T) {
+	checkFilter(t, "url_encode", "foo+1@example.com", nil, nil, "foo%2B1%40example.com")
+	checkFilter(t, "url_encode", "a b~c-d_e.f/é", nil, nil, "a+b~c-d_e.f%2F%C3%A9")
+	checkFilter(t, "url_encode", 1, nil, nil, "1")
+	checkFilter(t, "url_encode", nil, nil, nil, nil)
+}
+
+func TestURLDecode(t *testing.T) {
+	checkFilter(t, "url_decode", "foo+bar", nil, nil, "foo bar")
+	checkFilter(t, "url_decode", "foo%20bar", nil, nil, "foo bar")
+	checkFilter(t, "url_decode", "foo%2B1%40example.com", nil, nil, "foo+1@example.com")
+	checkFilter(t, "url_decode", "%C3%A9 100% %zz", nil, nil, "é 100% %zz")
+	checkFilter(t, "url_decode", 1, nil, nil, "1")
+	checkFilter(t, "url_decode", nil, nil, nil, nil)
+	checkFilterError(t, "url_decode", "%ff")
+}
+
+func TestStripHTML(t *testing.T) {
+	checkFilter(t, "strip_html", `<div>test</div>`, nil, nil, "test")
+	checkFilter(t, "strip_html", `<div id="test">test</div>`, nil, nil, "test")
+	checkFilter(t, "strip_html", `<script type='text/javascript'>document.write"some stuff";</script>`, nil, nil, "")
+	checkFilter(t, "strip_html", `<style type='text/css'>foo bar</style>`, nil, nil, "")
+	checkFilter(t, "strip_html", "<div\nclass='multiline'>test</div>", nil, nil, "test")
+	checkFilter(t, "strip_html", "<!-- foo bar \n test -->test", nil, nil, "test")
+	checkFilter(t, "strip_html", nil, nil, nil, "")
+	checkFilter(t, "strip_html", "<<<script </script>script>foo;</script>", nil, nil, "foo;")
+}
+
+func TestNewlineToBr(t *testing.T) {
+	checkFilter(t, "newline_to_br", "a\nb\nc", nil, nil, "a<br />\nb<br />\nc")
+	checkFilter(t, "newline_to_br", "a\r\nb\nc", nil, nil, "a<br />\nb<br />\nc")
+	checkFilter(t, "newline_to_br", nil, nil, nil, "")
+}
+
+func TestBase64(t *testing.T) {
+	checkFilter(t, "base64_encode", "one two three", nil, nil, "b25lIHR3byB0aHJlZQ==")
+	checkFilter(t, "base64_encode", nil, nil, nil, "")
+	checkFilter(t, "base64_decode", "b25lIHR3byB0aHJlZQ==", nil, nil, "one two three")
+	checkFilterError(t, "base64_decode", "invalidbase64")
+	checkFilterError(t, "base64_decode", "b25lIHR3\nbyB0aHJlZQ==")
+
+	rawData := "<>`~!@#$%^&*(){}[];'\"~?"
+	checkFilter(t, "base64_url_safe_encode", "abcdefghijklmnopqrstuvwxyz ABCDEFGHIJKLMNOPQRSTUVWXYZ 1234567890 !@#$%^&*()-=_+/?.:;[]{}\\|", nil, nil,
+		"YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXogQUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVogMTIzNDU2Nzg5MCAhQCMkJV4mKigpLT1fKy8_Ljo7W117fVx8")
+	checkFilter(t, "base64_url_safe_encode", rawData, nil, nil, "PD5gfiFAIyQlXiYqKCl7fVtdOycifj8=")
+	checkFilter(t, "base64_url_safe_decode", "PD5gfiFAIyQlXiYqKCl7fVtdOycifj8=", nil, nil, rawData)
+	checkFilter(t, "base64_url_safe_decode", "PD5gfiFAIyQlXiYqKCl7fVtdOycifj8", nil, nil, rawData)
+	checkFilterError(t, "base64_url_safe_decode", "invalidbase64")
+
+	_, err := standardFilters["base64_decode"]("invalidbase64", nil, nil)
+	if err == nil || err.Error() != "Liquid error: invalid base64 provided to base64_decode" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHTMLFiltersInTemplates(t *testing.T) {
+	vars := Vars{"comment": "<b>hi</b> & \"bye\"\n", "query": "shoes & socks"}
+	checkTemplateRender(t, `{{ comment | escape | newline_to_br }}`, vars, "&lt;b&gt;hi&lt;/b&gt; &amp; &quot;bye&quot;<br />\n")
+	checkTemplateRender(t, `{{ comment | strip_html | strip }}`, vars, "hi & \"bye\"")
+	checkTemplateRender(t, `/search?q={{ query | url_encode }}`, vars, "/search?q=shoes+%26+socks")
+}
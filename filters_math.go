@@ -0,0 +1,411 @@
+package liquid
+
+import (
+	"math"
+	"math/big"
+)
+
+// Math filters from Liquid::StandardFilters. Operands are converted with toNumber,
+// so numeric strings work as numbers. Integer operations stay integers, matching
+// ruby's Integer arithmetic, while anything involving a decimal is done exactly
+// and converted back to a float
+
+// ratOf converts a number returned by toNumber into an exact decimal
+func ratOf(n interface{}) *big.Rat {
+	switch n.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n.(int)))
+	case *big.Rat:
+		return n.(*big.Rat)
+	}
+	return nil
+}
+
+// floatOf converts a number returned by toNumber into a float
+func floatOf(n interface{}) float64 {
+	switch n.(type) {
+	case int:
+		return float64(n.(int))
+	case *big.Rat:
+		return fromRat(n.(*big.Rat))
+	}
+	return n.(float64)
+}
+
+// fromNumber converts a number returned by toNumber or an operation into
+// a filter result, decimals become floats as with ruby's BigDecimal#to_f
+func fromNumber(n interface{}) interface{} {
+	if r, ok := n.(*big.Rat); ok {
+		return fromRat(r)
+	}
+	return n
+}
+
+// arithmeticFilter builds a filter applying a binary operation to the input and argument, the analog
+// to apply_operation. NaN and Infinity can't be represented exactly, so they use float arithmetic
+func arithmeticFilter(
+	intOp func(a, b int) (int, error),
+	ratOp func(a, b *big.Rat) (*big.Rat, error),
+	floatOp func(a, b float64) float64,
+) filterFunc {
+	return func(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		a, b := toNumber(input), toNumber(args[0])
+
+		x, aIsInt := a.(int)
+		y, bIsInt := b.(int)
+		if aIsInt && bIsInt {
+			return intOp(x, y)
+		}
+
+		if p, q := ratOf(a), ratOf(b); p != nil && q != nil {
+			result, err := ratOp(p, q)
+			if err != nil {
+				return nil, err
+			}
+			return fromRat(result), nil
+		}
+
+		return floatOp(floatOf(a), floatOf(b)), nil
+	}
+}
+
+// floorDiv is ruby's Integer#/, which rounds towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// floorMod is ruby's Integer#%, where the result has the sign of the divisor
+func floorMod(a, b int) int {
+	m := a % b
+	if m != 0 && ((m < 0) != (b < 0)) {
+		m += b
+	}
+	return m
+}
+
+// ratFloor rounds towards negative infinity. big.Int's Div is euclidean,
+// which is the same as flooring as the denominator is always positive
+func ratFloor(r *big.Rat) *big.Int {
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+func ratCeil(r *big.Rat) *big.Int {
+	return new(big.Int).Neg(ratFloor(new(big.Rat).Neg(r)))
+}
+
+// ratRound rounds to the given number of decimal places, with halves rounded
+// away from zero like ruby's round. Negative digits round to tens, hundreds etc.
+// Digits are checked against the number before it's scaled, so huge ones are cheap
+func ratRound(r *big.Rat, digits int) *big.Rat {
+	if digits >= decimalPlaces(r) {
+		return r
+	}
+	if -digits > integerDigits(r) {
+		return new(big.Rat)
+	}
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(digits))), nil))
+
+	x := new(big.Rat)
+	if digits >= 0 {
+		x.Mul(r, scale)
+	} else {
+		x.Quo(r, scale)
+	}
+
+	q, m := new(big.Int).QuoRem(new(big.Int).Abs(x.Num()), x.Denom(), new(big.Int))
+	if m.Lsh(m, 1).Cmp(x.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if x.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	x.SetInt(q)
+	if digits >= 0 {
+		return x.Quo(x, scale)
+	}
+	return x.Mul(x, scale)
+}
+
+// decimalPlaces counts the digits after the decimal point. toNumber only produces
+// terminating decimals, whose denominators only have the factors 2 and 5
+func decimalPlaces(r *big.Rat) int {
+	denom := new(big.Int).Set(r.Denom())
+	twos := int(denom.TrailingZeroBits())
+	denom.Rsh(denom, uint(twos))
+
+	fives := 0
+	five, m := big.NewInt(5), new(big.Int)
+	for denom.Cmp(big.NewInt(1)) > 0 {
+		if denom.QuoRem(denom, five, m); m.Sign() != 0 {
+			break
+		}
+		fives++
+	}
+
+	if twos > fives {
+		return twos
+	}
+	return fives
+}
+
+// integerDigits counts the digits before the decimal point, none for numbers less than 1
+func integerDigits(r *big.Rat) int {
+	i := new(big.Int).Quo(new(big.Int).Abs(r.Num()), r.Denom())
+	if i.Sign() == 0 {
+		return 0
+	}
+	return len(i.String())
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// ratToInt converts an integral big.Int into an int
+func ratToInt(i *big.Int) int {
+	return int(i.Int64())
+}
+
+// integerOverflow is raised when integer arithmetic doesn't fit in an int. Ruby
+// would promote the result to a Bignum, which integers here have no analog to
+func integerOverflow(filter string) error {
+	return argumentError("integer overflow in %v", filter)
+}
+
+// addInts adds two ints, reporting whether the sum overflowed
+func addInts(a, b int) (int, bool) {
+	sum := a + b
+	return sum, (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0)
+}
+
+// mulInts multiplies two ints, reporting whether the product overflowed
+func mulInts(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, false
+	}
+	product := a * b
+	return product, product/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt)
+}
+
+// floatDomainError is raised when a float that can't be represented as an integer is converted
+func floatDomainError(f float64) error {
+	switch {
+	case math.IsNaN(f):
+		return FloatDomainError{Detail{Message: "NaN"}}
+	case f < 0:
+		return FloatDomainError{Detail{Message: "-Infinity"}}
+	}
+	return FloatDomainError{Detail{Message: "Infinity"}}
+}
+
+var (
+	// {{ 1 | plus: 2 }} #=> 3
+	plusFilter = arithmeticFilter(
+		func(a, b int) (int, error) {
+			if sum, overflow := addInts(a, b); !overflow {
+				return sum, nil
+			}
+			return 0, integerOverflow("plus")
+		},
+		func(a, b *big.Rat) (*big.Rat, error) { return new(big.Rat).Add(a, b), nil },
+		func(a, b float64) float64 { return a + b },
+	)
+
+	// {{ 4 | minus: 2 }} #=> 2
+	minusFilter = arithmeticFilter(
+		func(a, b int) (int, error) {
+			if b != math.MinInt {
+				if difference, overflow := addInts(a, -b); !overflow {
+					return difference, nil
+				}
+			} else if a < 0 {
+				return a - b, nil
+			}
+			return 0, integerOverflow("minus")
+		},
+		func(a, b *big.Rat) (*big.Rat, error) { return new(big.Rat).Sub(a, b), nil },
+		func(a, b float64) float64 { return a - b },
+	)
+
+	// {{ 3 | times: 4 }} #=> 12
+	timesFilter = arithmeticFilter(
+		func(a, b int) (int, error) {
+			if product, overflow := mulInts(a, b); !overflow {
+				return product, nil
+			}
+			return 0, integerOverflow("times")
+		},
+		func(a, b *big.Rat) (*big.Rat, error) { return new(big.Rat).Mul(a, b), nil },
+		func(a, b float64) float64 { return a * b },
+	)
+
+	// divided_by rounds down when both operands are integers
+	//
+	// {{ 7 | divided_by: 2 }} #=> 3
+	// {{ 7 | divided_by: 2.0 }} #=> 3.5
+	dividedByFilter = arithmeticFilter(
+		func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, ZeroDivisionError{}
+			}
+			if a == math.MinInt && b == -1 {
+				return 0, integerOverflow("divided_by")
+			}
+			return floorDiv(a, b), nil
+		},
+		func(a, b *big.Rat) (*big.Rat, error) {
+			if b.Sign() == 0 {
+				return nil, ZeroDivisionError{}
+			}
+			return new(big.Rat).Quo(a, b), nil
+		},
+		func(a, b float64) float64 { return a / b },
+	)
+
+	// {{ 7 | modulo: 3 }} #=> 1
+	moduloFilter = arithmeticFilter(
+		func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, ZeroDivisionError{}
+			}
+			return floorMod(a, b), nil
+		},
+		func(a, b *big.Rat) (*big.Rat, error) {
+			if b.Sign() == 0 {
+				return nil, ZeroDivisionError{}
+			}
+			q := new(big.Rat).SetInt(ratFloor(new(big.Rat).Quo(a, b)))
+			return new(big.Rat).Sub(a, q.Mul(q, b)), nil
+		},
+		math.Mod,
+	)
+)
+
+// round rounds to the nearest integer, or to a number of decimal places
+//
+// {{ 4.6 | round }} #=> 5
+// {{ 4.5612 | round: 2 }} #=> 4.56
+func roundFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	digits, err := toInteger(optionalArg(args, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := toNumber(input); n.(type) {
+	case int:
+		if digits >= 0 {
+			return n, nil
+		}
+		return ratToInt(ratRound(ratOf(n), digits).Num()), nil
+	case *big.Rat:
+		result := ratRound(n.(*big.Rat), digits)
+		if digits == 0 {
+			return ratToInt(result.Num()), nil
+		}
+		return fromRat(result), nil
+	default:
+		f := n.(float64)
+		if digits == 0 {
+			return nil, floatDomainError(f)
+		}
+		return f, nil
+	}
+}
+
+// {{ 1.2 | ceil }} #=> 2
+func ceilFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch n := toNumber(input); n.(type) {
+	case int:
+		return n, nil
+	case *big.Rat:
+		return ratToInt(ratCeil(n.(*big.Rat))), nil
+	default:
+		return nil, floatDomainError(n.(float64))
+	}
+}
+
+// {{ 1.8 | floor }} #=> 1
+func floorFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch n := toNumber(input); n.(type) {
+	case int:
+		return n, nil
+	case *big.Rat:
+		return ratToInt(ratFloor(n.(*big.Rat))), nil
+	default:
+		return nil, floatDomainError(n.(float64))
+	}
+}
+
+// {{ -3 | abs }} #=> 3
+func absFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+
+	switch n := toNumber(input); n.(type) {
+	case int:
+		return abs(n.(int)), nil
+	case *big.Rat:
+		return fromRat(new(big.Rat).Abs(n.(*big.Rat))), nil
+	default:
+		return math.Abs(n.(float64)), nil
+	}
+}
+
+// boundFilter builds at_least and at_most, which return the input or the
+// argument, whichever keep is true for
+func boundFilter(keep func(cmp int) bool) filterFunc {
+	return func(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+
+		a, b := toNumber(input), toNumber(args[0])
+
+		var cmp int
+		if p, q := ratOf(a), ratOf(b); p != nil && q != nil {
+			cmp = p.Cmp(q)
+		} else {
+			x, y := floatOf(a), floatOf(b)
+			cmp = compareOrdered(x < y, x > y)
+		}
+
+		if keep(cmp) {
+			return fromNumber(a), nil
+		}
+		return fromNumber(b), nil
+	}
+}
+
+var (
+	// {{ 4 | at_least: 5 }} #=> 5
+	atLeastFilter = boundFilter(func(cmp int) bool { return cmp >= 0 })
+
+	// {{ 4 | at_most: 3 }} #=> 3
+	atMostFilter = boundFilter(func(cmp int) bool { return cmp <= 0 })
+)
@@ -0,0 +1,174 @@
+package liquid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// math filters from integration/standard_filter_test.rb
+
+func TestPlus(t *testing.T) {
+	checkFilter(t, "plus", 1, args(1), nil, 2)
+	checkFilter(t, "plus", "1", args("1"), nil, 2)
+	checkFilter(t, "plus", "1", args(1.0), nil, 2.0)
+	checkFilter(t, "plus", 0.1, args(0.2), nil, 0.3)
+	checkFilter(t, "plus", "2.1", args("0.1"), nil, 2.2)
+	checkFilter(t, "plus", "foo", args(1), nil, 1)
+	checkFilter(t, "plus", nil, args(3), nil, 3)
+	checkFilter(t, "plus", int64(1), args(uint8(2)), nil, 3)
+
+	// integers which overflow raise an error rather than wrapping
+	checkFilter(t, "plus", math.MinInt, args(math.MaxInt), nil, -1)
+	checkFilterError(t, "plus", "9223372036854775807", 1)
+	checkFilterError(t, "plus", math.MinInt, -1)
+}
+
+func TestMinus(t *testing.T) {
+	checkFilter(t, "minus", 4, args(1), nil, 3)
+	checkFilter(t, "minus", "4.3", args("2"), nil, 2.3)
+	checkFilter(t, "minus", 1, args(2.5), nil, -1.5)
+	checkFilter(t, "minus", -1, args(math.MinInt), nil, math.MaxInt)
+	checkFilterError(t, "minus", math.MinInt, 1)
+	checkFilterError(t, "minus", 0, math.MinInt)
+}
+
+func TestTimes(t *testing.T) {
+	checkFilter(t, "times", 3, args(4), nil, 12)
+	checkFilter(t, "times", "foo", args(4), nil, 0)
+	checkFilter(t, "times", "2.1", args(3), nil, 6.3)
+	checkFilter(t, "times", 0.0725, args(100), nil, 7.25)
+	checkFilter(t, "times", "-0.0725", args(100), nil, -7.25)
+	checkFilter(t, "times", "-0.0725", args(-100), nil, 7.25)
+	checkFilter(t, "times", math.MaxInt, args(-1), nil, -math.MaxInt)
+	checkFilterError(t, "times", math.MinInt, -1)
+	checkFilterError(t, "times", -1, math.MinInt)
+	checkFilterError(t, "times", 1<<32, 1<<31)
+
+	tpl, _ := ParseTemplate(`{{ 5 | times: 99999999999 | times: 99999999999 }}`)
+	checkRenderError(t, tpl, nil, "line 1, column 1: Liquid error: integer overflow in times")
+}
+
+func TestDividedBy(t *testing.T) {
+	checkFilter(t, "divided_by", 12, args(3), nil, 4)
+	checkFilter(t, "divided_by", 14, args(3), nil, 4)
+	checkFilter(t, "divided_by", 15, args(3), nil, 5)
+	checkFilterError(t, "divided_by", math.MinInt, -1)
+	checkFilter(t, "divided_by", -7, args(2), nil, -4)
+	checkFilter(t, "divided_by", 7, args(-2), nil, -4)
+	checkFilter(t, "divided_by", 2.0, args(4), nil, 0.5)
+	checkFilter(t, "divided_by", "5", args("2"), nil, 2)
+	checkFilter(t, "divided_by", "5.0", args("2"), nil, 2.5)
+	checkFilter(t, "divided_by", 1, args(3.0), nil, 1.0/3.0)
+
+	for _, divisor := range []interface{}{0, 0.0, "0", "foo", nil} {
+		_, err := standardFilters["divided_by"](5, args(divisor), nil)
+		if _, ok := err.(ZeroDivisionError); !ok {
+			t.Errorf("5 | divided_by: %#v should have failed with a ZeroDivisionError, got: %v", divisor, err)
+		}
+	}
+}
+
+func TestModulo(t *testing.T) {
+	checkFilter(t, "modulo", 3, args(2), nil, 1)
+	checkFilter(t, "modulo", -7, args(3), nil, 2)
+	checkFilter(t, "modulo", 7, args(-3), nil, -2)
+	checkFilter(t, "modulo", 5.5, args(2), nil, 1.5)
+	checkFilter(t, "modulo", "-5.5", args(2), nil, 0.5)
+
+	_, err := standardFilters["modulo"](1, args(0), nil)
+	if _, ok := err.(ZeroDivisionError); !ok {
+		t.Errorf("1 | modulo: 0 should have failed with a ZeroDivisionError, got: %v", err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	checkFilter(t, "round", 4.6, nil, nil, 5)
+	checkFilter(t, "round", "4.3", nil, nil, 4)
+	checkFilter(t, "round", 2.5, nil, nil, 3)
+	checkFilter(t, "round", -2.5, nil, nil, -3)
+	checkFilter(t, "round", 4.5612, args(2), nil, 4.56)
+	checkFilter(t, "round", 1.005, args(2), nil, 1.01)
+	checkFilter(t, "round", 15, args(-1), nil, 20)
+	checkFilter(t, "round", 7, args(2), nil, 7)
+	checkFilter(t, "round", math.Inf(1), args(2), nil, math.Inf(1))
+	checkFilter(t, "round", 555, args(-3), nil, 1000)
+	checkFilter(t, "round", "-0.125", args(2), nil, -0.13)
+
+	// digits past the number's precision or magnitude don't need scaling
+	checkFilter(t, "round", 1.5, args(1), nil, 1.5)
+	checkFilter(t, "round", 1.5, args(1000000000), nil, 1.5)
+	checkFilter(t, "round", 5, args(-1000000000), nil, 0)
+	checkFilter(t, "round", 999.5, args(-4), nil, 0.0)
+	checkFilter(t, "round", "-123.456", args(-1000000000), nil, 0.0)
+
+	_, err := standardFilters["round"](math.Inf(1), nil, nil)
+	if err == nil || err.Error() != "Liquid error: Infinity" {
+		t.Errorf("Infinity | round should have failed with a FloatDomainError, got: %v", err)
+	}
+}
+
+func TestCeilFloor(t *testing.T) {
+	checkFilter(t, "ceil", 4.6, nil, nil, 5)
+	checkFilter(t, "ceil", "4.3", nil, nil, 5)
+	checkFilter(t, "ceil", -4.3, nil, nil, -4)
+	checkFilter(t, "ceil", 4, nil, nil, 4)
+	checkFilter(t, "floor", 4.6, nil, nil, 4)
+	checkFilter(t, "floor", "4.3", nil, nil, 4)
+	checkFilter(t, "floor", -4.3, nil, nil, -5)
+
+	_, err := standardFilters["floor"](math.NaN(), nil, nil)
+	if err == nil || err.Error() != "Liquid error: NaN" {
+		t.Errorf("NaN | floor should have failed with a FloatDomainError, got: %v", err)
+	}
+}
+
+func TestAbs(t *testing.T) {
+	checkFilter(t, "abs", 17, nil, nil, 17)
+	checkFilter(t, "abs", -17, nil, nil, 17)
+	checkFilter(t, "abs", "-17", nil, nil, 17)
+	checkFilter(t, "abs", "-17.5", nil, nil, 17.5)
+	checkFilter(t, "abs", 0, nil, nil, 0)
+	checkFilter(t, "abs", "foo", nil, nil, 0)
+}
+
+func TestAtLeastAtMost(t *testing.T) {
+	checkFilter(t, "at_least", 5, args(4), nil, 5)
+	checkFilter(t, "at_least", 5, args(6), nil, 6)
+	checkFilter(t, "at_least", 5, args(4.5), nil, 5)
+	checkFilter(t, "at_least", "4.5", args(4), nil, 4.5)
+	checkFilter(t, "at_least", nil, args(1), nil, 1)
+	checkFilter(t, "at_most", 5, args(4), nil, 4)
+	checkFilter(t, "at_most", 5, args(6), nil, 5)
+	checkFilter(t, "at_most", 5, args(4.5), nil, 4.5)
+	checkFilter(t, "at_most", "4.5", args("5"), nil, 4.5)
+}
+
+func TestMathFiltersInTemplates(t *testing.T) {
+	vars := Vars{"price": "19.99", "quantity": "3", "total": 10}
+	checkTemplateRender(t, `{{ price | times: quantity }}`, vars, "59.97")
+	checkTemplateRender(t, `{{ quantity | plus: 1 }}`, vars, "4")
+	checkTemplateRender(t, `{{ total | divided_by: 4 }}`, vars, "2")
+	checkTemplateRender(t, `{{ total | divided_by: 4.0 }}`, vars, "2.5")
+	checkTemplateRender(t, `{{ 6 | divided_by: 2.0 }}`, vars, "3.0")
+	checkTemplateRender(t, `{{ price | round }}`, vars, "20")
+}
+
+func TestZeroDivisionRendersInline(t *testing.T) {
+	tpl, err := ParseTemplate(`{{ 1 | divided_by: 0 }} done`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tpl.Render(nil); !errors.Is(err, ZeroDivisionError{}) {
+		t.Errorf("expected a ZeroDivisionError, got: %v", err)
+	}
+
+	got, err := tpl.Render(nil, WithInlineErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Liquid error (line 1): divided by 0 done"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,373 @@
+package liquid
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String filters from Liquid::StandardFilters. Inputs are converted to strings
+// with ruby's to_s semantics, and lengths and offsets count characters not bytes
+
+// rubyWhitespace is the set of characters removed by ruby's strip
+const rubyWhitespace = " \t\n\v\f\r\x00"
+
+// upcaseSpecialCases are the unconditional mappings from SpecialCasing.txt,
+// which ruby applies but unicode.ToUpper doesn't as they produce several runes
+var upcaseSpecialCases = map[rune]string{
+	'ß': "SS",
+	'ŉ': "ʼN",
+	'ǰ': "J̌",
+	'ΐ': "Ϊ́",
+	'ΰ': "Ϋ́",
+	'և': "ԵՒ",
+	'ẖ': "H̱",
+	'ẗ': "T̈",
+	'ẘ': "W̊",
+	'ẙ': "Y̊",
+	'ẚ': "Aʾ",
+	'ﬀ': "FF",
+	'ﬁ': "FI",
+	'ﬂ': "FL",
+	'ﬃ': "FFI",
+	'ﬄ': "FFL",
+	'ﬅ': "ST",
+	'ﬆ': "ST",
+	'ﬓ': "ՄՆ",
+	'ﬔ': "ՄԵ",
+	'ﬕ': "ՄԻ",
+	'ﬖ': "ՎՆ",
+	'ﬗ': "ՄԽ",
+}
+
+// titlecaseSpecialCases are used by capitalize, falling back to upcaseSpecialCases
+var titlecaseSpecialCases = map[rune]string{
+	'ß': "Ss",
+	'և': "Եւ",
+	'ﬀ': "Ff",
+	'ﬁ': "Fi",
+	'ﬂ': "Fl",
+	'ﬃ': "Ffi",
+	'ﬄ': "Ffl",
+	'ﬅ': "St",
+	'ﬆ': "St",
+	'ﬓ': "Մն",
+	'ﬔ': "Մե",
+	'ﬕ': "Մի",
+	'ﬖ': "Վն",
+	'ﬗ': "Մխ",
+}
+
+var downcaseSpecialCases = map[rune]string{
+	'İ': "i̇",
+}
+
+func mapRunes(s string, special map[rune]string, mapping func(rune) rune) string {
+	var output bytes.Buffer
+	for _, r := range s {
+		if replacement, ok := special[r]; ok {
+			output.WriteString(replacement)
+		} else {
+			output.WriteRune(mapping(r))
+		}
+	}
+	return output.String()
+}
+
+func rubyUpcase(s string) string {
+	return mapRunes(s, upcaseSpecialCases, unicode.ToUpper)
+}
+
+func rubyDowncase(s string) string {
+	return mapRunes(s, downcaseSpecialCases, unicode.ToLower)
+}
+
+// rubyCapitalize converts the first character to titlecase and the rest to lowercase
+func rubyCapitalize(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+
+	title, ok := titlecaseSpecialCases[first]
+	if !ok {
+		title, ok = upcaseSpecialCases[first]
+	}
+	if !ok {
+		title = string(unicode.ToTitle(first))
+	}
+
+	return title + rubyDowncase(s[size:])
+}
+
+// rubySliceBounds is the analog to the bounds checking of ruby's String#slice and
+// Array#slice with an offset and length. Negative offsets count back from the end,
+// and ok is false in the cases where ruby would return nil
+func rubySliceBounds(size, offset, length int) (start, end int, ok bool) {
+	if offset < 0 {
+		offset += size
+	}
+	if offset < 0 || offset > size || length < 0 {
+		return 0, 0, false
+	}
+
+	end = offset + length
+	if end > size || end < offset {
+		end = size
+	}
+	return offset, end, true
+}
+
+func isRubySpace(r rune) bool {
+	return r < utf8.RuneSelf && strings.ContainsRune(" \t\n\v\f\r", r)
+}
+
+// rubySplit is the analog to ruby's String#split. A single space splits on runs of
+// whitespace, an empty pattern splits into characters, and trailing empty strings are removed
+func rubySplit(s, pattern string) []string {
+	var parts []string
+	switch pattern {
+	case " ":
+		parts = strings.FieldsFunc(s, isRubySpace)
+	case "":
+		parts = strings.Split(s, "")
+	default:
+		parts = strings.Split(s, pattern)
+	}
+
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// stringFilter adapts functions of the input string and a fixed number of
+// string arguments into filters
+func stringFilter(arity int, fn func(input string, args []string) string) filterFunc {
+	return func(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		if err := checkArgs(args, arity, arity); err != nil {
+			return nil, err
+		}
+		strArgs := make([]string, len(args))
+		for i, arg := range args {
+			strArgs[i] = toS(arg)
+		}
+		return fn(toS(input), strArgs), nil
+	}
+}
+
+var (
+	// {{ 'foo' | append: 'bar' }} #=> 'foobar'
+	appendFilter = stringFilter(1, func(input string, args []string) string {
+		return input + args[0]
+	})
+
+	// {{ 'bar' | prepend: 'foo' }} #=> 'foobar'
+	prependFilter = stringFilter(1, func(input string, args []string) string {
+		return args[0] + input
+	})
+
+	// {{ 'my great title' | capitalize }} #=> 'My great title'
+	capitalizeFilter = stringFilter(0, func(input string, args []string) string {
+		return rubyCapitalize(input)
+	})
+
+	upcaseFilter = stringFilter(0, func(input string, args []string) string {
+		return rubyUpcase(input)
+	})
+
+	downcaseFilter = stringFilter(0, func(input string, args []string) string {
+		return rubyDowncase(input)
+	})
+
+	stripFilter = stringFilter(0, func(input string, args []string) string {
+		return strings.Trim(input, rubyWhitespace)
+	})
+
+	lstripFilter = stringFilter(0, func(input string, args []string) string {
+		return strings.TrimLeft(input, rubyWhitespace)
+	})
+
+	rstripFilter = stringFilter(0, func(input string, args []string) string {
+		return strings.TrimRight(input, rubyWhitespace)
+	})
+
+	stripNewlinesFilter = stringFilter(0, func(input string, args []string) string {
+		return strings.Replace(strings.Replace(input, "\r\n", "", -1), "\n", "", -1)
+	})
+
+	removeFilter = stringFilter(1, func(input string, args []string) string {
+		return strings.Replace(input, args[0], "", -1)
+	})
+
+	removeFirstFilter = stringFilter(1, func(input string, args []string) string {
+		return strings.Replace(input, args[0], "", 1)
+	})
+
+	removeLastFilter = stringFilter(1, func(input string, args []string) string {
+		return replaceLast(input, args[0], "")
+	})
+)
+
+// {{ 'a-b-c' | replace: '-', '+' }} #=> 'a+b+c'
+func replaceFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	return strings.Replace(toS(input), toS(args[0]), toS(optionalArg(args, 1, "")), -1), nil
+}
+
+func replaceFirstFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	return strings.Replace(toS(input), toS(args[0]), toS(optionalArg(args, 1, "")), 1), nil
+}
+
+func replaceLastFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	return replaceLast(toS(input), toS(args[0]), toS(args[1])), nil
+}
+
+func replaceLast(input, old, replacement string) string {
+	i := strings.LastIndex(input, old)
+	if i < 0 {
+		return input
+	}
+	return input[:i] + replacement + input[i+len(old):]
+}
+
+// slice returns the characters of a string, or the items of an array, starting
+// at the offset. Length defaults to 1
+//
+// {{ 'hello' | slice: 1, 3 }} #=> 'ell'
+// {{ 'hello' | slice: -2 }} #=> 'l'
+func sliceFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	offset, err := toInteger(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	length := 1
+	if len(args) > 1 && args[1] != nil {
+		if length, err = toInteger(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if array, ok := interfaceToExpression(input).(arrayExpr); ok {
+		start, end, ok := rubySliceBounds(len(array), offset, length)
+		if !ok {
+			return []interface{}{}, nil
+		}
+		return []interface{}(array[start:end]), nil
+	}
+
+	runes := []rune(toS(input))
+	start, end, ok := rubySliceBounds(len(runes), offset, length)
+	if !ok {
+		return "", nil
+	}
+	return string(runes[start:end]), nil
+}
+
+// {{ 'a,b,c' | split: ',' }} #=> ['a', 'b', 'c']
+func splitFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	parts := rubySplit(toS(input), toS(args[0]))
+	output := make([]interface{}, len(parts))
+	for i, part := range parts {
+		output[i] = part
+	}
+	return output, nil
+}
+
+// truncate shortens a string to length characters, including the truncate string
+//
+// {{ 'Ground control to Major Tom.' | truncate: 20 }} #=> 'Ground control to...'
+func truncateFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 2); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, nil
+	}
+
+	length, err := toInteger(optionalArg(args, 0, 50))
+	if err != nil {
+		return nil, err
+	}
+	ellipsis := []rune(toS(optionalArg(args, 1, "...")))
+	runes := []rune(toS(input))
+
+	if len(runes) <= length {
+		return string(runes), nil
+	}
+
+	l := length - len(ellipsis)
+	if l < 0 {
+		l = 0
+	}
+	return string(runes[:l]) + string(ellipsis), nil
+}
+
+// truncatewords shortens a string to a number of words, adding the truncate string
+// if anything was removed. Whitespace between the remaining words is collapsed
+//
+// {{ 'Ground control to Major Tom.' | truncatewords: 3 }} #=> 'Ground control to...'
+func truncatewordsFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 2); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		return nil, nil
+	}
+
+	words, err := toInteger(optionalArg(args, 0, 15))
+	if err != nil {
+		return nil, err
+	}
+	if words <= 0 {
+		words = 1
+	}
+
+	s := toS(input)
+	wordlist := strings.FieldsFunc(s, isRubySpace)
+	if len(wordlist) <= words {
+		return s, nil
+	}
+
+	return strings.Join(wordlist[:words], " ") + toS(optionalArg(args, 1, "...")), nil
+}
+
+// default returns the default value if the input is nil, false or empty. With
+// allow_false: true, false is returned as is instead of being replaced
+//
+// {{ product.title | default: 'Untitled' }}
+// {{ settings.enabled | default: true, allow_false: true }}
+func defaultFilter(input interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 1); err != nil {
+		return nil, err
+	}
+
+	value := interfaceToExpression(input)
+	missing := !isTruthy(value)
+	if isTruthy(interfaceToExpression(kwargs["allow_false"])) {
+		missing = value == Nil
+	}
+
+	if missing || Empty.send(value) {
+		return optionalArg(args, 0, ""), nil
+	}
+	return input, nil
+}
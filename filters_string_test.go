@@ -0,0 +1,194 @@
+package liquid
+
+import "testing"
+
+// string filters from integration/standard_filter_test.rb
+
+func args(values ...interface{}) []interface{} {
+	return values
+}
+
+func TestDowncase(t *testing.T) {
+	checkFilter(t, "downcase", "Testing", nil, nil, "testing")
+	checkFilter(t, "downcase", nil, nil, nil, "")
+	checkFilter(t, "downcase", "ÀÉÎÕÜ ΣΑΣ", nil, nil, "àéîõü σασ")
+	checkFilter(t, "downcase", "İ", nil, nil, "i̇")
+}
+
+func TestUpcase(t *testing.T) {
+	checkFilter(t, "upcase", "Testing", nil, nil, "TESTING")
+	checkFilter(t, "upcase", nil, nil, nil, "")
+	checkFilter(t, "upcase", "àéîõü", nil, nil, "ÀÉÎÕÜ")
+	checkFilter(t, "upcase", "straße ﬁne", nil, nil, "STRASSE FINE")
+}
+
+func TestCapitalize(t *testing.T) {
+	checkFilter(t, "capitalize", "testing", nil, nil, "Testing")
+	checkFilter(t, "capitalize", "hELLO wORLD", nil, nil, "Hello world")
+	checkFilter(t, "capitalize", "élan", nil, nil, "Élan")
+	checkFilter(t, "capitalize", "ßa", nil, nil, "Ssa")
+	checkFilter(t, "capitalize", "ǆemal", nil, nil, "ǅemal")
+	checkFilter(t, "capitalize", "", nil, nil, "")
+	checkFilter(t, "capitalize", 1, nil, nil, "1")
+}
+
+func TestSlice(t *testing.T) {
+	checkFilter(t, "slice", "foobar", args(1, 3), nil, "oob")
+	checkFilter(t, "slice", "foobar", args(1, 1000), nil, "oobar")
+	checkFilter(t, "slice", "foobar", args(1, 0), nil, "")
+	checkFilter(t, "slice", "foobar", args(1, 1), nil, "o")
+	checkFilter(t, "slice", "foobar", args(3, 3), nil, "bar")
+	checkFilter(t, "slice", "foobar", args(-2, 2), nil, "ar")
+	checkFilter(t, "slice", "foobar", args(-2, 1000), nil, "ar")
+	checkFilter(t, "slice", "foobar", args(-1), nil, "r")
+	checkFilter(t, "slice", nil, args(0), nil, "")
+	checkFilter(t, "slice", "foobar", args(100, 10), nil, "")
+	checkFilter(t, "slice", "foobar", args(-100, 10), nil, "")
+	checkFilter(t, "slice", "foobar", args(6), nil, "")
+	checkFilter(t, "slice", "foobar", args("1", "3"), nil, "oob")
+	checkFilterError(t, "slice", "foobar", nil)
+	checkFilterError(t, "slice", "foobar", 0, "")
+	checkFilterError(t, "slice", "foobar", 1.5)
+}
+
+func TestSliceUnicode(t *testing.T) {
+	checkFilter(t, "slice", "测试测试", args(1, 2), nil, "试测")
+	checkFilter(t, "slice", "héllo", args(-4, 3), nil, "éll")
+	checkFilter(t, "slice", "👍🏽ok", args(0, 2), nil, "👍🏽")
+}
+
+func TestSliceOnArrays(t *testing.T) {
+	input := []interface{}{"f", "o", "o", "b", "a", "r"}
+	checkFilter(t, "slice", input, args(1, 3), nil, []interface{}{"o", "o", "b"})
+	checkFilter(t, "slice", input, args(1, 1000), nil, []interface{}{"o", "o", "b", "a", "r"})
+	checkFilter(t, "slice", input, args(1, 0), nil, []interface{}{})
+	checkFilter(t, "slice", input, args(1, 1), nil, []interface{}{"o"})
+	checkFilter(t, "slice", input, args(3, 3), nil, []interface{}{"b", "a", "r"})
+	checkFilter(t, "slice", input, args(-2, 2), nil, []interface{}{"a", "r"})
+	checkFilter(t, "slice", input, args(-2, 1000), nil, []interface{}{"a", "r"})
+	checkFilter(t, "slice", input, args(-1), nil, []interface{}{"r"})
+	checkFilter(t, "slice", input, args(100, 10), nil, []interface{}{})
+	checkFilter(t, "slice", input, args(-100, 10), nil, []interface{}{})
+}
+
+func TestTruncate(t *testing.T) {
+	checkFilter(t, "truncate", "1234567890", args(7), nil, "1234...")
+	checkFilter(t, "truncate", "1234567890", args(20), nil, "1234567890")
+	checkFilter(t, "truncate", "1234567890", args(0), nil, "...")
+	checkFilter(t, "truncate", "1234567890", nil, nil, "1234567890")
+	checkFilter(t, "truncate", "测试测试测试测试", args(5), nil, "测试...")
+	checkFilter(t, "truncate", "1234567890", args(5, 1), nil, "12341")
+	checkFilter(t, "truncate", "Ground control to Major Tom.", args(20, "…"), nil, "Ground control to M…")
+	checkFilter(t, "truncate", nil, args(5), nil, nil)
+}
+
+func TestSplit(t *testing.T) {
+	checkFilter(t, "split", "12~34", args("~"), nil, []interface{}{"12", "34"})
+	checkFilter(t, "split", "A? ~ ~ ~ ,Z", args("~ ~ ~"), nil, []interface{}{"A? ", " ,Z"})
+	checkFilter(t, "split", "A?Z", args("~"), nil, []interface{}{"A?Z"})
+	checkFilter(t, "split", nil, args(" "), nil, []interface{}{})
+	checkFilter(t, "split", "A1Z", args(1), nil, []interface{}{"A", "Z"})
+	checkFilter(t, "split", "a,b,,c,,", args(","), nil, []interface{}{"a", "b", "", "c"})
+	checkFilter(t, "split", "  one  two\tthree\n", args(" "), nil, []interface{}{"one", "two", "three"})
+	checkFilter(t, "split", "héllo", args(""), nil, []interface{}{"h", "é", "l", "l", "o"})
+}
+
+func TestTruncateWords(t *testing.T) {
+	checkFilter(t, "truncatewords", "one two three", args(4), nil, "one two three")
+	checkFilter(t, "truncatewords", "one two three", args(3), nil, "one two three")
+	checkFilter(t, "truncatewords", "one two three", args(2), nil, "one two...")
+	checkFilter(t, "truncatewords", "one two three", nil, nil, "one two three")
+	checkFilter(t, "truncatewords",
+		"Two small (13&#8221; x 5.5&#8221; x 10&#8221; high) baskets fit inside one large basket (13&#8221; x 16&#8221; x 10.5&#8221; high) with cover.",
+		args(15), nil,
+		"Two small (13&#8221; x 5.5&#8221; x 10&#8221; high) baskets fit inside one large basket (13&#8221;...")
+	checkFilter(t, "truncatewords", "测试测试测试测试", args(5), nil, "测试测试测试测试")
+	checkFilter(t, "truncatewords", "one two three", args(2, 1), nil, "one two1")
+	checkFilter(t, "truncatewords", "one  two\tthree\nfour", args(3), nil, "one two three...")
+	checkFilter(t, "truncatewords", "one two three four", args(2), nil, "one two...")
+	checkFilter(t, "truncatewords", "one two three four", args(0), nil, "one...")
+}
+
+func TestStrip(t *testing.T) {
+	checkTemplateRender(t, `{{ source | strip }}`, Vars{"source": " ab c  "}, "ab c")
+	checkTemplateRender(t, `{{ source | strip }}`, Vars{"source": " \tab c  \n \t"}, "ab c")
+	// ruby doesn't consider non-ascii spaces to be whitespace
+	checkFilter(t, "strip", "\u00a0ab\u00a0", nil, nil, "\u00a0ab\u00a0")
+}
+
+func TestLstrip(t *testing.T) {
+	checkTemplateRender(t, `{{ source | lstrip }}`, Vars{"source": " ab c  "}, "ab c  ")
+	checkTemplateRender(t, `{{ source | lstrip }}`, Vars{"source": " \tab c  \n \t"}, "ab c  \n \t")
+}
+
+func TestRstrip(t *testing.T) {
+	checkTemplateRender(t, `{{ source | rstrip }}`, Vars{"source": " ab c  "}, " ab c")
+	checkTemplateRender(t, `{{ source | rstrip }}`, Vars{"source": " \tab c  \n \t"}, " \tab c")
+}
+
+func TestStripNewlines(t *testing.T) {
+	checkTemplateRender(t, `{{ source | strip_newlines }}`, Vars{"source": "a\nb\nc"}, "abc")
+	checkTemplateRender(t, `{{ source | strip_newlines }}`, Vars{"source": "a\r\nb\nc"}, "abc")
+	checkFilter(t, "strip_newlines", "a\rb", nil, nil, "a\rb")
+}
+
+func TestReplace(t *testing.T) {
+	checkFilter(t, "replace", "1 1 1 1", args("1", 2), nil, "2 2 2 2")
+	checkFilter(t, "replace", "1 1 1 1", args(1, 2), nil, "2 2 2 2")
+	checkFilter(t, "replace", "1 1 1 1", args(1), nil, "   ")
+	checkFilter(t, "replace_first", "1 1 1 1", args("1", 2), nil, "2 1 1 1")
+	checkFilter(t, "replace_first", "1 1 1 1", args(1, 2), nil, "2 1 1 1")
+	checkFilter(t, "replace_last", "1 1 1 1", args("1", 2), nil, "1 1 1 2")
+	checkFilter(t, "replace_last", "1 1 1 1", args(1, 2), nil, "1 1 1 2")
+	checkFilter(t, "replace_last", "1 1 1 1", args("x", 2), nil, "1 1 1 1")
+	checkTemplateRender(t, `{{ '1 1 1 1' | replace_first: '1', 2 }}`, nil, "2 1 1 1")
+	checkTemplateRender(t, `{{ '1 1 1 1' | replace_last: '1', 2 }}`, nil, "1 1 1 2")
+}
+
+func TestRemove(t *testing.T) {
+	checkFilter(t, "remove", "a a a a", args("a"), nil, "   ")
+	checkFilter(t, "remove", "1 1 1 1", args(1), nil, "   ")
+	checkFilter(t, "remove_first", "a a a a", args("a "), nil, "a a a")
+	checkFilter(t, "remove_first", "1 1 1 1", args(1), nil, " 1 1 1")
+	checkFilter(t, "remove_last", "a a a a", args(" a"), nil, "a a a")
+	checkFilter(t, "remove_last", "1 1 1 1", args(1), nil, "1 1 1 ")
+	checkTemplateRender(t, `{{ 'a a a a' | remove_first: 'a ' }}`, nil, "a a a")
+	checkTemplateRender(t, `{{ 'a a a a' | remove_last: ' a' }}`, nil, "a a a")
+}
+
+func TestAppend(t *testing.T) {
+	vars := Vars{"a": "bc", "b": "d"}
+	checkTemplateRender(t, `{{ a | append: 'd'}}`, vars, "bcd")
+	checkTemplateRender(t, `{{ a | append: b}}`, vars, "bcd")
+	checkFilter(t, "append", 1, args(2.5), nil, "12.5")
+}
+
+func TestPrepend(t *testing.T) {
+	vars := Vars{"a": "bc", "b": "a"}
+	checkTemplateRender(t, `{{ a | prepend: 'a'}}`, vars, "abc")
+	checkTemplateRender(t, `{{ a | prepend: b}}`, vars, "abc")
+}
+
+func TestDefault(t *testing.T) {
+	checkFilter(t, "default", "foo", args("bar"), nil, "foo")
+	checkFilter(t, "default", nil, args("bar"), nil, "bar")
+	checkFilter(t, "default", "", args("bar"), nil, "bar")
+	checkFilter(t, "default", false, args("bar"), nil, "bar")
+	checkFilter(t, "default", []interface{}{}, args("bar"), nil, "bar")
+	checkFilter(t, "default", map[string]interface{}{}, args("bar"), nil, "bar")
+	checkFilter(t, "default", 0, args("bar"), nil, 0)
+	checkFilter(t, "default", nil, nil, nil, "")
+	checkTemplateRender(t, `{{ missing | default: 'bar' }}`, nil, "bar")
+}
+
+func TestDefaultHandleFalse(t *testing.T) {
+	allowFalse := map[string]interface{}{"allow_false": true}
+	checkFilter(t, "default", "foo", args("bar"), allowFalse, "foo")
+	checkFilter(t, "default", nil, args("bar"), allowFalse, "bar")
+	checkFilter(t, "default", "", args("bar"), allowFalse, "bar")
+	checkFilter(t, "default", false, args("bar"), allowFalse, false)
+	checkFilter(t, "default", []interface{}{}, args("bar"), allowFalse, "bar")
+	checkFilter(t, "default", map[string]interface{}{}, args("bar"), allowFalse, "bar")
+	checkTemplateRender(t, `{{ false | default: 'bar', allow_false: true }}`, nil, "false")
+	checkTemplateRender(t, `{{ false | default: 'bar', allow_false: false }}`, nil, "bar")
+}
@@ -0,0 +1,138 @@
+package liquid
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// integration/standard_filter_test.rb
+
+// checkFilter invokes a standard filter directly, bypassing the template
+func checkFilter(t *testing.T, name string, input interface{}, args []interface{}, kwargs map[string]interface{}, want interface{}) {
+	fn, ok := lookupFilter(name)
+	if !ok {
+		t.Errorf("filter %v doesn't exist", name)
+		return
+	}
+
+	ctx := newContext()
+	got, err := fn(&ctx, input, args, kwargs)
+	if err != nil {
+		t.Errorf("%v(%#v, %#v) returned error: %v", name, input, args, err)
+		return
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%v(%#v, %#v) want: %#v, got: %#v", name, input, args, want, got)
+	}
+}
+
+// checkFilterError checks that a filter fails with an ArgumentError
+func checkFilterError(t *testing.T, name string, input interface{}, args ...interface{}) {
+	fn, _ := lookupFilter(name)
+	ctx := newContext()
+	_, err := fn(&ctx, input, args, nil)
+	if _, ok := err.(ArgumentError); !ok {
+		t.Errorf("%v(%#v, %#v) should have failed with an argument error, got: %v", name, input, args, err)
+	}
+}
+
+func TestFilterArgumentCount(t *testing.T) {
+	tests := []struct {
+		markup string
+		want   string
+	}{
+		{`{{ 'a' | append }}`, "line 1, column 1: Liquid error: wrong number of arguments (given 1, expected 2)"},
+		{`{{ 'a' | upcase: 1 }}`, "line 1, column 1: Liquid error: wrong number of arguments (given 2, expected 1)"},
+		{`{{ 'a' | replace: 1, 2, 3 }}`, "line 1, column 1: Liquid error: wrong number of arguments (given 4, expected 2..3)"},
+	}
+
+	for _, test := range tests {
+		tpl, err := ParseTemplate(test.markup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tpl.Render(nil); err == nil || err.Error() != test.want {
+			t.Errorf("%v want error: %v, got: %v", test.markup, test.want, err)
+		}
+	}
+}
+
+func TestUnknownFiltersAreIgnored(t *testing.T) {
+	checkTemplateRender(t, `{{ 'a' | not_a_filter | upcase }}`, nil, "A")
+}
+
+func TestStrictFiltersAtRender(t *testing.T) {
+	tpl, err := ParseTemplate(`{{ 'a' | upcse }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tpl.Render(nil, WithStrictFilters())
+	if !errors.Is(err, UndefinedFilter{Name: "upcse"}) {
+		t.Errorf("want an undefined filter error, got: %v", err)
+	}
+	if err.Error() != "line 1, column 1: Liquid error: undefined filter upcse" {
+		t.Errorf("unexpected error message: %v", err)
+	}
+
+	got, err := tpl.Render(nil, WithStrictFilters(), WithInlineErrors())
+	if want := "Liquid error (line 1): undefined filter upcse"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+
+	// filters which are never reached aren't checked
+	tpl, _ = ParseTemplate(`{% if false %}{{ 'a' | upcse }}{% endif %}{{ 'a' | upcase }}`)
+	if got, err := tpl.Render(nil, WithStrictFilters()); err != nil || got != "A" {
+		t.Errorf("want: %q, got: %q (%v)", "A", got, err)
+	}
+}
+
+func TestStrictFiltersAtParse(t *testing.T) {
+	for _, markup := range []string{
+		`{{ 'a' | upcse }}`,
+		`{{ 'a' | upcase | downcse }}`,
+		`{% if true %}{% else %}{{ 'a' | upcse }}{% endif %}`,
+	} {
+		if _, err := ParseTemplate(markup, WithStrictFilters()); err == nil {
+			t.Errorf("%v should have failed to parse", markup)
+		} else if !errors.Is(err, UndefinedFilter{}) {
+			t.Errorf("%v want an undefined filter error, got: %v", markup, err)
+		}
+	}
+
+	if _, err := ParseTemplate(`{{ 'a' | upcse }}`); err != nil {
+		t.Errorf("unknown filters should only be found with strict filters, got: %v", err)
+	}
+	for _, markup := range []string{
+		`{{ 'a' | upcase | date: '%Y' }}`,
+		`{% comment %}{{ 'a' | upcse }}{% endcomment %}`,
+	} {
+		if _, err := ParseTemplate(markup, WithStrictFilters()); err != nil {
+			t.Errorf("%v failed to parse: %v", markup, err)
+		}
+	}
+}
+
+func TestStrictFiltersOnEngine(t *testing.T) {
+	engine := NewEngine(WithStrictFilters())
+	if _, err := engine.ParseTemplate(`{{ 'a' | shout }}`); !errors.Is(err, UndefinedFilter{Name: "shout"}) {
+		t.Errorf("want an undefined filter error, got: %v", err)
+	}
+
+	engine.RegisterFilter("shout", func(s string) string { return s + "!" })
+	tpl, err := engine.ParseTemplate(`{{ 'a' | shout }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(nil); err != nil || got != "a!" {
+		t.Errorf("want: %q, got: %q (%v)", "a!", got, err)
+	}
+}
+
+func TestFilterArgumentsAreEvaluated(t *testing.T) {
+	checkTemplateRender(t, `{{ a | append: b }}`, Vars{"a": "bc", "b": "d"}, "bcd")
+	checkTemplateRender(t, `{{ a | append: b.c }}`, Vars{"a": "bc", "b": Vars{"c": "d"}}, "bcd")
+	checkTemplateRender(t, `{{ nil | append: 'cat' }}`, nil, "cat")
+}
@@ -0,0 +1,3 @@
+module github.com/hownowstephen/go-liquid
+
+go 1.20
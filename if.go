@@ -0,0 +1,176 @@
+package liquid
+
+import (
+	"fmt"
+)
+
+// ifTag parses `if` blocks, and `unless` blocks when Unless is set.
+//
+//	{% if user.admin %}
+//	  Admin user!
+//	{% elsif user.moderator %}
+//	  Moderator
+//	{% else %}
+//	  Not admin user
+//	{% endif %}
+type ifTag struct {
+	Unless bool
+}
+
+func (t *ifTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
+
+	subctx := ctx.nested(fmt.Sprintf("end%v", name), map[string]Tag{
+		"elsif": &elseTag{Params: true},
+		"else":  &elseTag{},
+	})
+
+	pos := ctx.Position()
+	nodelist, err := tokensToNodeList(tokenizer, subctx)
+	if err != nil {
+		return nil, err
+	}
+
+	condition, err := parseCondition(tagMarkup(markup), ctx.engine)
+	if err != nil {
+		return nil, err
+	}
+
+	node := ifNode{
+		tag:     name,
+		markup:  markup,
+		inverse: t.Unless,
+		pos:     pos,
+		blocks:  []conditionalBlock{{condition: condition}},
+	}
+
+	// split the nodelist into blocks at each elsif/else
+	for _, n := range nodelist {
+		if e, ok := n.(elseNode); ok {
+			block := conditionalBlock{}
+			if e.tag == "elsif" {
+				if block.condition, err = parseCondition(tagMarkup(e.markup), ctx.engine); err != nil {
+					return nil, ctx.template.errorAt(err, e.pos)
+				}
+			}
+			node.blocks = append(node.blocks, block)
+			continue
+		}
+
+		last := &node.blocks[len(node.blocks)-1]
+		last.nodes = append(last.nodes, n)
+	}
+
+	return node, nil
+}
+
+// parseCondition is the analog to If#strict_parse, converting the markup of an
+// if tag into a Condition. `and` and `or` chain conditions from right to left,
+// so `a or b and c` is evaluated as `a or (b and c)`
+func parseCondition(markup string, engine *Engine) (*Condition, error) {
+	p, err := newParser(markup, engine)
+	if err != nil {
+		return nil, SyntaxError{Detail{Err: err}}
+	}
+
+	condition, err := parseComparison(p, engine)
+	if err != nil {
+		return nil, err
+	}
+	first := condition
+
+	for {
+		var child *Condition
+		switch {
+		case p.id("and"):
+			if child, err = parseComparison(p, engine); err != nil {
+				return nil, err
+			}
+			condition.and = append(condition.and, child)
+		case p.id("or"):
+			if child, err = parseComparison(p, engine); err != nil {
+				return nil, err
+			}
+			condition.or = append(condition.or, child)
+		default:
+			if _, err := p.consume(tEndOfString); err != nil {
+				return nil, SyntaxError{Detail{Err: err}}
+			}
+			return first, nil
+		}
+		condition = child
+	}
+}
+
+func parseComparison(p *Parser, engine *Engine) (*Condition, error) {
+	a, err := p.expression()
+	if err != nil {
+		return nil, SyntaxError{Detail{Err: err}}
+	}
+
+	if operator, err := p.consume(tComparisonOperator); err == nil {
+		b, err := p.expression()
+		if err != nil {
+			return nil, SyntaxError{Detail{Err: err}}
+		}
+		return newCondition(engine, ParseExpression(a), operator, ParseExpression(b))
+	}
+
+	return NewCondition(ParseExpression(a), "", nil)
+}
+
+// conditionalBlock is a list of nodes rendered when its condition is true.
+// else blocks have no condition, and are always rendered
+type conditionalBlock struct {
+	condition *Condition
+	nodes     []Node
+}
+
+func (b conditionalBlock) evaluate(ctx *Context) (bool, error) {
+	if b.condition == nil {
+		return true, nil
+	}
+	return b.condition.Evaluate(*ctx)
+}
+
+type ifNode struct {
+	tag     string
+	markup  string
+	inverse bool
+	blocks  []conditionalBlock
+	pos     Position
+}
+
+// Position returns where the if tag starts in the template
+func (n ifNode) Position() Position {
+	return n.pos
+}
+
+func (n ifNode) Render(ctx *Context) (string, error) {
+	for i, block := range n.blocks {
+		result, err := block.evaluate(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		// For unless, the first condition is interpreted backwards (if not)
+		if i == 0 && n.inverse {
+			result = !result
+		}
+
+		if result {
+			return renderNodes(block.nodes, ctx)
+		}
+	}
+	return "", nil
+}
+
+func (n ifNode) Blank() bool {
+	for _, block := range n.blocks {
+		for _, node := range block.nodes {
+			if !node.Blank() {
+				return false
+			}
+		}
+	}
+	return true
+}
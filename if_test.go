@@ -0,0 +1,183 @@
+package liquid
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// integration/tags/if_else_tag_test.rb
+
+func TestIf(t *testing.T) {
+	checkTemplateRender(t, ` {% if false %} this text should not go into the output {% endif %} `, nil, `  `)
+	checkTemplateRender(t, ` {% if true %} this text should go into the output {% endif %} `, nil, `  this text should go into the output  `)
+	checkTemplateRender(t, `{% if false %} you suck {% endif %} {% if true %} you rock {% endif %}?`, nil, `  you rock ?`)
+}
+
+func TestLiteralComparisons(t *testing.T) {
+	checkTemplateRender(t, `{% if v %} NO {% endif %}`, Vars{"v": false}, ``)
+	checkTemplateRender(t, `{% if null == nil %} YES {% endif %}`, nil, ` YES `)
+}
+
+func TestIfElse(t *testing.T) {
+	checkTemplateRender(t, `{% if false %} NO {% else %} YES {% endif %}`, nil, ` YES `)
+	checkTemplateRender(t, `{% if true %} YES {% else %} NO {% endif %}`, nil, ` YES `)
+	checkTemplateRender(t, `{% if "foo" %} YES {% else %} NO {% endif %}`, nil, ` YES `)
+}
+
+func TestIfBoolean(t *testing.T) {
+	checkTemplateRender(t, `{% if var %} YES {% endif %}`, Vars{"var": true}, ` YES `)
+}
+
+func TestIfOr(t *testing.T) {
+	checkTemplateRender(t, `{% if a or b %} YES {% endif %}`, Vars{"a": true, "b": true}, ` YES `)
+	checkTemplateRender(t, `{% if a or b %} YES {% endif %}`, Vars{"a": true, "b": false}, ` YES `)
+	checkTemplateRender(t, `{% if a or b %} YES {% endif %}`, Vars{"a": false, "b": true}, ` YES `)
+	checkTemplateRender(t, `{% if a or b %} YES {% endif %}`, Vars{"a": false, "b": false}, ``)
+
+	checkTemplateRender(t, `{% if a or b or c %} YES {% endif %}`, Vars{"a": false, "b": false, "c": true}, ` YES `)
+	checkTemplateRender(t, `{% if a or b or c %} YES {% endif %}`, Vars{"a": false, "b": false, "c": false}, ``)
+}
+
+func TestIfOrWithOperators(t *testing.T) {
+	checkTemplateRender(t, `{% if a == true or b == true %} YES {% endif %}`, Vars{"a": true, "b": true}, ` YES `)
+	checkTemplateRender(t, `{% if a == true or b == false %} YES {% endif %}`, Vars{"a": true, "b": true}, ` YES `)
+	checkTemplateRender(t, `{% if a == false or b == false %} YES {% endif %}`, Vars{"a": true, "b": true}, ``)
+}
+
+func TestIfAnd(t *testing.T) {
+	checkTemplateRender(t, `{% if true and true %} YES {% endif %}`, nil, ` YES `)
+	checkTemplateRender(t, `{% if false and true %} YES {% endif %}`, nil, ``)
+	checkTemplateRender(t, `{% if false and true %} YES {% endif %}`, nil, ``)
+}
+
+func TestAndOrEvaluateRightToLeft(t *testing.T) {
+	// parsed as `true or (false and false)`
+	checkTemplateRender(t, `{% if true or false and false %} YES {% endif %}`, nil, ` YES `)
+	// parsed as `true and (false or true)`
+	checkTemplateRender(t, `{% if true and false or true %} YES {% endif %}`, nil, ` YES `)
+	// parsed as `false and (true or true)`
+	checkTemplateRender(t, `{% if false and true or true %} YES {% endif %}`, nil, ``)
+}
+
+func TestIfElsif(t *testing.T) {
+	checkTemplateRender(t, `{% if 0 == 0 %}0{% elsif 1 == 1%}1{% else %}2{% endif %}`, nil, `0`)
+	checkTemplateRender(t, `{% if 0 != 0 %}0{% elsif 1 == 1%}1{% else %}2{% endif %}`, nil, `1`)
+	checkTemplateRender(t, `{% if 0 != 0 %}0{% elsif 1 != 1%}1{% else %}2{% endif %}`, nil, `2`)
+	checkTemplateRender(t, `{% if false %}if{% elsif true %}elsif{% endif %}`, nil, `elsif`)
+}
+
+func TestIfWithVariables(t *testing.T) {
+	checkTemplateRender(t, `{% if customer %}Hi {{ customer.name }}{% else %}Hi stranger{% endif %}`, Vars{"customer": Vars{"name": "Tobi"}}, `Hi Tobi`)
+	checkTemplateRender(t, `{% if customer %}Hi {{ customer.name }}{% else %}Hi stranger{% endif %}`, nil, `Hi stranger`)
+	checkTemplateRender(t, `{% if title == empty %}Untitled{% endif %}`, Vars{"title": ""}, `Untitled`)
+	checkTemplateRender(t, `{% if items != empty %}{{ items.size }} items{% endif %}`, Vars{"items": []interface{}{1, 2}}, `2 items`)
+}
+
+func TestTruthiness(t *testing.T) {
+	var nilPointer *struct{}
+	var nilMap map[string]interface{}
+	var nilSlice []interface{}
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{false, ""},
+		{true, "YES"},
+		{0, "YES"},
+		{"", "YES"},
+		{[]interface{}{}, "YES"},
+		{map[string]interface{}{}, "YES"},
+		{&struct{}{}, "YES"},
+		{nilPointer, ""},
+		{nilMap, ""},
+		{nilSlice, ""},
+	}
+
+	for _, test := range tests {
+		checkTemplateRender(t, `{% if value %}YES{% endif %}`, Vars{"value": test.value}, test.want)
+	}
+}
+
+func TestUnless(t *testing.T) {
+	checkTemplateRender(t, ` {% unless true %} this text should not go into the output {% endunless %} `, nil, `  `)
+	checkTemplateRender(t, ` {% unless false %} this text should go into the output {% endunless %} `, nil, `  this text should go into the output  `)
+	checkTemplateRender(t, `{% unless true %} you suck {% endunless %} {% unless false %} you rock {% endunless %}?`, nil, `  you rock ?`)
+	checkTemplateRender(t, `{% unless true %} NO {% else %} YES {% endunless %}`, nil, ` YES `)
+	checkTemplateRender(t, `{% unless false %} YES {% else %} NO {% endunless %}`, nil, ` YES `)
+	checkTemplateRender(t, `{% unless title == blank %}{{ title }}{% endunless %}`, Vars{"title": "  "}, ``)
+	checkTemplateRender(t, `{% unless title == blank %}{{ title }}{% endunless %}`, Vars{"title": "Hello"}, `Hello`)
+}
+
+func TestSyntaxErrorInCondition(t *testing.T) {
+	for _, markup := range []string{`{% if %}{% endif %}`, `{% if a == %}{% endif %}`, `{% if a b %}{% endif %}`} {
+		_, err := ParseTemplate(markup)
+		if !errors.Is(err, SyntaxError{}) {
+			t.Errorf("%v should have raised a syntax error, got: %v", markup, err)
+		}
+	}
+}
+
+func TestComparisonErrorsRenderInline(t *testing.T) {
+	tpl, err := ParseTemplate(`{% if '1' > 0 %}yes{% endif %} done`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tpl.Render(nil); err == nil {
+		t.Error("expected an argument error")
+	}
+
+	got, err := tpl.Render(nil, WithInlineErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Liquid error (line 1): comparison of String with 0 failed done"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestIfWithCustomOperators(t *testing.T) {
+	RegisterOperator("startswith", func(left, right interface{}) (bool, error) {
+		l, _ := left.(string)
+		r, _ := right.(string)
+		return strings.HasPrefix(l, r), nil
+	})
+	RegisterOperator("in", func(left, right interface{}) (bool, error) {
+		values, _ := right.([]interface{})
+		for _, v := range values {
+			if v == left {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	RegisterOperator("matches", func(left, right interface{}) (bool, error) {
+		pattern, err := regexp.Compile(fmt.Sprint(right))
+		if err != nil {
+			return false, err
+		}
+		return pattern.MatchString(fmt.Sprint(left)), nil
+	})
+	defer unregisterOperator("startswith")
+	defer unregisterOperator("in")
+	defer unregisterOperator("matches")
+
+	vars := Vars{"title": "Sale: Shoes", "tags": []interface{}{"sale", "summer"}}
+	checkTemplateRender(t, `{% if title startswith 'Sale' %}YES{% endif %}`, vars, `YES`)
+	checkTemplateRender(t, `{% if title startswith 'Shoes' %}YES{% else %}NO{% endif %}`, vars, `NO`)
+	checkTemplateRender(t, `{% if 'sale' in tags and title matches '^S.*s$' %}YES{% endif %}`, vars, `YES`)
+	checkTemplateRender(t, `{% if 'winter' in tags %}YES{% else %}NO{% endif %}`, vars, `NO`)
+
+	tpl, err := ParseTemplate(`{% if title matches '(' %}YES{% endif %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpl.Render(vars); err == nil {
+		t.Error("expected errors from the operator to be returned")
+	}
+}
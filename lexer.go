@@ -57,9 +57,9 @@ type sequence struct {
 	regex *regexp.Regexp
 }
 
-// Types of sequences to look for, in priority order
+// Types of sequences to look for, in priority order. Comparison operators
+// are matched before any of these, see Engine.matchOperator
 var sequenceTypes = []sequence{
-	{tComparisonOperator, regexp.MustCompile(`^==|!=|<>|<=?|>=?|contains`)},
 	{tSingleStringLiteral, regexp.MustCompile(`^'[^\']*'`)},
 	{tDoubleStringLiteral, regexp.MustCompile(`^"[^\"]*"`)},
 	{tNumberLiteral, regexp.MustCompile(`^-?\d+(\.\d+)?`)},
@@ -71,6 +71,11 @@ var whitespace = regexp.MustCompile(`\s`)
 
 // Lexer converts liquid-y strings into lexographic tokens
 func Lexer(s string) ([]Token, error) {
+	return lex(s, nil)
+}
+
+// lex converts strings into tokens like Lexer, also matching the engine's operators
+func lex(s string, engine *Engine) ([]Token, error) {
 
 	s = strings.TrimSpace(s)
 	var tokens []Token
@@ -83,6 +88,12 @@ TokenLoop:
 			continue
 		}
 
+		if match := engine.orDefault().matchOperator(s[i:]); match != "" {
+			tokens = append(tokens, Token{tComparisonOperator, match})
+			i += len(match) - 1
+			continue
+		}
+
 		for _, seq := range sequenceTypes {
 			if match := seq.regex.FindString(s[i:]); match != "" {
 				tokens = append(tokens, Token{seq.name, match})
@@ -92,3 +92,40 @@ func TestUnexpectedCharacter(t *testing.T) {
 		t.Error(`Should raise an error for '%'`)
 	}
 }
+
+func TestComparisonOperators(t *testing.T) {
+	checkLexerTokens(t, "a contains 'b'", []Token{
+		{tIdentifier, "a"},
+		{tComparisonOperator, "contains"},
+		{tSingleStringLiteral, "'b'"},
+		EndOfString,
+	})
+	checkLexerTokens(t, "a<=b", []Token{
+		{tIdentifier, "a"},
+		{tComparisonOperator, "<="},
+		{tIdentifier, "b"},
+		EndOfString,
+	})
+	// operators must be whole words
+	checkLexerTokens(t, "containsAll", []Token{
+		{tIdentifier, "containsAll"},
+		EndOfString,
+	})
+}
+
+func TestRegisteredComparisonOperators(t *testing.T) {
+	RegisterOperator("has", func(left, right interface{}) (bool, error) { return false, nil })
+	RegisterOperator("=~", func(left, right interface{}) (bool, error) { return false, nil })
+	defer unregisterOperator("has")
+	defer unregisterOperator("=~")
+
+	checkLexerTokens(t, "tags has 'sale' hash =~ '.*'", []Token{
+		{tIdentifier, "tags"},
+		{tComparisonOperator, "has"},
+		{tSingleStringLiteral, "'sale'"},
+		{tIdentifier, "hash"},
+		{tComparisonOperator, "=~"},
+		{tSingleStringLiteral, "'.*'"},
+		EndOfString,
+	})
+}
@@ -24,4 +24,11 @@ var (
 	partialTemplateParserRegexp = regexp.MustCompile(fmt.Sprintf(`(?ms)%v.*?%v|%v.*?%v`, tagStartRegexp, tagEndRegexp, variableStartRegexp, variableIncompleteEndRegexp))
 	templateParserRegexp        = regexp.MustCompile(fmt.Sprintf(`(?ms)(%v|%v)`, partialTemplateParserRegexp, anyStartingTagRegexp))
 	variableParserRegexp        = regexp.MustCompile(fmt.Sprintf(`\[[^\]]+\]|%v+\??`, variableSegmentRegexp))
+
+	// lax variable parsing from Liquid::Variable
+	markupWithQuotedFragmentRegexp = regexp.MustCompile(fmt.Sprintf(`(?s)(%v)(.*)`, quotedFragmentRegexp))
+	filterMarkupRegexp             = regexp.MustCompile(fmt.Sprintf(`(?s)%v\s*(.*)`, filterSeparatorRegexp))
+	filterParserRegexp             = regexp.MustCompile(fmt.Sprintf(`(?:\s+|%v|,)+`, quotedFragmentRegexp))
+	filterArgsRegexp               = regexp.MustCompile(fmt.Sprintf(`(?:\:|,)\s*((?:\w+\s*\:\s*)?(?:%v))`, quotedFragmentRegexp))
+	filterNameRegexp               = regexp.MustCompile(`\w+`)
 )
@@ -0,0 +1,274 @@
+package liquid
+
+import "time"
+
+// Option configures how templates are parsed and rendered
+type Option func(*options)
+
+type options struct {
+	errorPolicy     ErrorPolicy
+	errorHandler    ErrorHandler
+	limits          ResourceLimits
+	parseLimits     ParseLimits
+	fileSystem      FileSystem
+	allowedTags     map[string]bool
+	deniedTags      map[string]bool
+	allowedFilters  map[string]bool
+	deniedFilters   map[string]bool
+	tagCheck        TagCheck
+	reflection      *ReflectionPolicy
+	nestingLimit    int
+	recursionLimit  int
+	strictFilters   bool
+	strictVariables bool
+	errorMode       ErrorMode
+	allErrors       bool
+	name            string
+	location        *time.Location
+	now             func() time.Time
+}
+
+// ErrorMode controls how parsing handles markup that isn't valid Liquid, as
+// Liquid::Template.error_mode does
+type ErrorMode int
+
+const (
+	// StrictMode stops the parse with the error, it is the default
+	StrictMode ErrorMode = iota
+	// WarnMode parses the markup laxly, and records the error as one of the template's Warnings
+	WarnMode
+	// LaxMode accepts any markup, ignoring what it can't make sense of
+	LaxMode
+)
+
+// WithErrorMode selects the error mode for parsing, so legacy templates can be
+// parsed laxly while new ones are held to strict
+func WithErrorMode(mode ErrorMode) Option {
+	return func(o *options) {
+		o.errorMode = mode
+	}
+}
+
+// WithTemplateName names the template being parsed, such as by its file name,
+// so that its errors say which template they were raised by
+func WithTemplateName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithAllErrors keeps parsing after an error, skipping the tag or variable that
+// raised it, so every problem of a template is found at once. ParseTemplate then
+// returns the partial template along with its first error, and the template's
+// Errors lists them all
+func WithAllErrors() Option {
+	return func(o *options) {
+		o.allErrors = true
+	}
+}
+
+// ErrorPolicy controls what happens when a node raises an error while rendering
+type ErrorPolicy int
+
+const (
+	// AbortOnError stops the render and returns the error, it is the default
+	AbortOnError ErrorPolicy = iota
+	// RenderErrorsInline renders the error's message in place of the node, as
+	// `Liquid error (line 3): divided by 0`, and carries on with the next one
+	RenderErrorsInline
+	// CollectErrors leaves the node out of the output and carries on, the
+	// render returns the whole output along with RenderErrors
+	CollectErrors
+)
+
+// ErrorHandler decides what to do with an error raised while rendering a node. It
+// returns the output to render in place of the node, or an error to abort the render
+type ErrorHandler func(err error) (string, error)
+
+// WithErrorPolicy selects what happens to the errors raised while rendering
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(o *options) {
+		o.errorPolicy = policy
+	}
+}
+
+// WithErrorHandler passes the errors raised while rendering to a handler, which
+// takes precedence over the ErrorPolicy
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// WithResourceLimits limits the work each render can do, going over a limit stops
+// the render with a ResourceLimitError whatever the ErrorPolicy
+func WithResourceLimits(limits ResourceLimits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// WithParseLimits limits the templates that can be parsed, going over a limit stops
+// the parse with a ResourceLimitError, even with WithAllErrors
+func WithParseLimits(limits ParseLimits) Option {
+	return func(o *options) {
+		o.parseLimits = limits
+	}
+}
+
+// WithFileSystem sets where partial templates are loaded from, usually given to
+// NewEngine. Without it, loading a partial fails with a FileSystemError
+func WithFileSystem(fs FileSystem) Option {
+	return func(o *options) {
+		o.fileSystem = fs
+	}
+}
+
+// WithAllowedTags only lets templates use the named tags, so that untrusted
+// templates can be sandboxed, usually given to NewEngine. Using any other tag is
+// a SyntaxError when parsing. It replaces any list of allowed tags given before
+func WithAllowedTags(names ...string) Option {
+	return func(o *options) {
+		o.allowedTags = nameSet(names)
+	}
+}
+
+// WithDeniedTags stops templates using the named tags, using one is a
+// SyntaxError when parsing. It replaces any list of denied tags given before
+func WithDeniedTags(names ...string) Option {
+	return func(o *options) {
+		o.deniedTags = nameSet(names)
+	}
+}
+
+// WithAllowedFilters only lets templates use the named filters, using any other
+// is a SyntaxError when parsing. It replaces any list of allowed filters given before
+func WithAllowedFilters(names ...string) Option {
+	return func(o *options) {
+		o.allowedFilters = nameSet(names)
+	}
+}
+
+// WithDeniedFilters stops templates using the named filters, using one is a
+// SyntaxError when parsing. It replaces any list of denied filters given before
+func WithDeniedFilters(names ...string) Option {
+	return func(o *options) {
+		o.deniedFilters = nameSet(names)
+	}
+}
+
+// TagCheck decides whether a template may use a tag, given its name and the markup
+// after the name, such as `'footer'` for `{% include 'footer' %}`. Returning an
+// error stops the parse with it
+type TagCheck func(name, markup string) error
+
+// WithTagCheck checks each tag a template uses once it has passed the allowed and
+// denied lists, for rules which depend on the markup, such as which partials can
+// be included
+//
+//	WithTagCheck(func(name, markup string) error {
+//		if name == "include" && markup != "'footer'" {
+//			return errors.New("only the footer can be included")
+//		}
+//		return nil
+//	})
+func WithTagCheck(check TagCheck) Option {
+	return func(o *options) {
+		o.tagCheck = check
+	}
+}
+
+// nameSet converts a list of names into a set
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// permitted reports whether a name passes an allowed and a denied list,
+// without an allowed list everything which isn't denied is allowed
+func permitted(name string, allowed, denied map[string]bool) bool {
+	return (allowed == nil || allowed[name]) && !denied[name]
+}
+
+// WithReflectionPolicy lets templates look up the fields and methods of Go
+// values, as far as the policy allows
+func WithReflectionPolicy(policy ReflectionPolicy) Option {
+	return func(o *options) {
+		o.reflection = &policy
+	}
+}
+
+// defaultDepthLimit is Liquid's Block::MAX_DEPTH
+const defaultDepthLimit = 100
+
+// WithNestingLimit sets how deeply blocks can be nested when parsing, the default
+// is 100. Templates which go deeper fail to parse with a StackLevelError
+func WithNestingLimit(depth int) Option {
+	return func(o *options) {
+		o.nestingLimit = depth
+	}
+}
+
+// WithRecursionLimit sets how deeply the nodes of a render can be nested, such as
+// by templates which include themselves, the default is 100. Going deeper raises a
+// StackLevelError instead of recursing until the stack overflows
+func WithRecursionLimit(depth int) Option {
+	return func(o *options) {
+		o.recursionLimit = depth
+	}
+}
+
+// depthLimit returns a nesting or recursion limit, or the default if it isn't set
+func depthLimit(limit int) int {
+	if limit <= 0 {
+		return defaultDepthLimit
+	}
+	return limit
+}
+
+// WithInlineErrors renders errors into the output in place of the node that
+// raised them, as Liquid::Template#render does, instead of stopping the render
+// and returning the error. It's short for WithErrorPolicy(RenderErrorsInline)
+func WithInlineErrors() Option {
+	return WithErrorPolicy(RenderErrorsInline)
+}
+
+// WithTimeZone sets the time zone used by the date filters for the current time,
+// unix timestamps and dates without a zone. time.Time values are converted to it
+// before formatting. Without it the local time zone is used and time.Time values
+// keep their own
+func WithTimeZone(loc *time.Location) Option {
+	return func(o *options) {
+		o.location = loc
+	}
+}
+
+// WithNow sets the clock the date filters read the current time from, for 'now'
+// and 'today' and as time_ago's default. It's converted to the render's time zone.
+// Without it time.Now is used, a fixed clock makes renders reproducible
+func WithNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithStrictFilters makes using a filter that doesn't exist an UndefinedFilter error,
+// instead of leaving the value unchanged. Passed to Render the error is raised when
+// the filter is reached, and passed to ParseTemplate (or NewEngine for both) unknown
+// filters are found while parsing, so typos can be caught before templates are used
+func WithStrictFilters() Option {
+	return func(o *options) {
+		o.strictFilters = true
+	}
+}
+
+// WithStrictVariables makes rendering a variable that doesn't exist, or looking up a
+// key it doesn't have, an UndefinedVariable error instead of evaluating to nil
+func WithStrictVariables() Option {
+	return func(o *options) {
+		o.strictVariables = true
+	}
+}
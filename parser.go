@@ -20,7 +20,12 @@ type Parser struct {
 
 // NewParser generates a parser object for consuming tokens
 func NewParser(input string) (*Parser, error) {
-	tokens, err := Lexer(input)
+	return newParser(input, nil)
+}
+
+// newParser generates a parser which also knows the engine's operators
+func newParser(input string, engine *Engine) (*Parser, error) {
+	tokens, err := lex(input, engine)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +58,19 @@ func (p *Parser) tryConsume(tType string) bool {
 	return true
 }
 
+// analog to `id?` in the ruby, consumes an identifier only if it matches str
+func (p *Parser) id(str string) bool {
+	if int(p.index) >= len(p.tokens) {
+		return false
+	}
+	token := p.tokens[p.index]
+	if token.name != tIdentifier || token.value != str {
+		return false
+	}
+	p.index++
+	return true
+}
+
 func (p *Parser) jump(count uint64) error {
 	p.index += count
 	if int(p.index) >= len(p.tokens) {
@@ -115,7 +133,6 @@ func (p *Parser) variableSignature() (string, error) {
 		result += expr
 		close, err := p.consume(tCloseSquare)
 		if err != nil {
-			fmt.Println("ALMOST, got:", result)
 			return "", err
 		}
 		result += close
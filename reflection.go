@@ -0,0 +1,219 @@
+package liquid
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ReflectionPolicy controls which parts of the Go values given to a render can be
+// reached from templates. Without a policy the fields and methods of structs can't
+// be looked up at all, only maps, slices and Drops. Unexported fields and methods
+// are never visible
+//
+//	WithReflectionPolicy(ReflectionPolicy{
+//		Fields:       true,
+//		DeniedFields: []string{"Password"},
+//		DeniedTypes:  []reflect.Type{reflect.TypeOf(Session{})},
+//	})
+//
+// Keys are matched to the name given by a `liquid` struct tag, the Go name, or the
+// Go name in snake case, so {{ user.first_name }} reads the FirstName field. A
+// field tagged `liquid:"-"` is never visible
+type ReflectionPolicy struct {
+	// Fields makes the exported fields of structs visible
+	Fields bool
+	// DeniedFields are the names of fields which are never visible, in any struct
+	DeniedFields []string
+
+	// Methods lets templates call the exported methods of values which take no
+	// arguments, or just a context.Context, and return a value, optionally with an
+	// error. Methods returning an error evaluate to nil when it isn't nil. Methods
+	// which only return an error, like Save() error, are never called
+	Methods bool
+	// AllowedMethods are the only methods which can be called on values of each
+	// type, by Go name. Values of the types listed can have these methods called
+	// even when Methods isn't set. Pointer types are listed by their element type
+	AllowedMethods map[reflect.Type][]string
+
+	// DeniedTypes are never visible, values of these types, or pointers to them,
+	// evaluate to nil. This includes Drops
+	DeniedTypes []reflect.Type
+}
+
+// denies reports whether the policy hides values of a type
+func (p *ReflectionPolicy) denies(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, denied := range p.DeniedTypes {
+		if t == denied {
+			return true
+		}
+	}
+	return false
+}
+
+// reveal replaces values the policy denies with nil
+func (p *ReflectionPolicy) reveal(e Expression) Expression {
+	if p == nil || len(p.DeniedTypes) == 0 {
+		return e
+	}
+	v := expressionToInterface(e)
+	if v != nil && p.denies(reflect.TypeOf(v)) {
+		return Nil
+	}
+	return e
+}
+
+// lookup reads the field or calls the method of a Go value named by key. The second
+// return value reports whether the policy allows the value to look up the key at all
+func (p *ReflectionPolicy) lookup(c *Context, object interface{}, key string) (Expression, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+
+	value := reflect.ValueOf(object)
+	if value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Struct {
+		value = value.Elem()
+	}
+	if p.Fields && value.Kind() == reflect.Struct {
+		if field, ok := p.field(value, key); ok {
+			return interfaceToExpression(field.Interface()), true, nil
+		}
+	}
+
+	// pointers have the methods of their element too
+	if method, ok := p.method(reflect.ValueOf(object), key); ok {
+		value, err := p.call(c, method, key)
+		return value, true, err
+	}
+	return nil, false, nil
+}
+
+// field finds the visible field named by key
+func (p *ReflectionPolicy) field(value reflect.Value, key string) (reflect.Value, bool) {
+	// the fields of embedded structs are promoted, as they are in Go
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		if !field.IsExported() || containsName(p.DeniedFields, field.Name) {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("liquid"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if key == tag || (tag == "" && matchesName(key, field.Name)) {
+			// embedded pointers can be nil
+			found, err := value.FieldByIndexErr(field.Index)
+			return found, err == nil
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// method finds the method named by key, if it can be called
+func (p *ReflectionPolicy) method(value reflect.Value, key string) (reflect.Value, bool) {
+	t := value.Type()
+	elem := t
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	allowed, listed := p.AllowedMethods[elem]
+	if !p.Methods && !listed {
+		return reflect.Value{}, false
+	}
+
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !method.IsExported() || !matchesName(key, method.Name) {
+			continue
+		}
+		if listed && !containsName(allowed, method.Name) {
+			continue
+		}
+
+		fn := value.Method(i)
+		if !callable(fn.Type()) {
+			continue
+		}
+		return fn, true
+	}
+	return reflect.Value{}, false
+}
+
+// callable reports whether a method takes no arguments, or just a context.Context,
+// and returns a value, optionally with an error. A lone error isn't a value, methods
+// like that are run for their side effects
+func callable(t reflect.Type) bool {
+	switch t.NumIn() {
+	case 0:
+	case 1:
+		if t.In(0) != contextType {
+			return false
+		}
+	default:
+		return false
+	}
+
+	switch t.NumOut() {
+	case 1:
+		return t.Out(0) != errorType
+	case 2:
+		return t.Out(1) == errorType
+	}
+	return false
+}
+
+// call calls a method, a method which panics raises an ArgumentError rather
+// than taking the render down with it
+func (p *ReflectionPolicy) call(c *Context, method reflect.Value, name string) (value Expression, err error) {
+	var in []reflect.Value
+	if method.Type().NumIn() == 1 {
+		in = []reflect.Value{reflect.ValueOf(c.Context())}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, argumentError("method '%v' panicked: %v", name, r)
+		}
+	}()
+
+	out := method.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return Nil, nil
+	}
+	return interfaceToExpression(out[0].Interface()), nil
+}
+
+// matchesName reports whether a key names a Go identifier, either as it is or in snake case
+func matchesName(key, name string) bool {
+	return key == name || key == snakeCase(name)
+}
+
+// snakeCase converts a Go identifier to snake case, keeping initialisms together,
+// so FirstName is first_name and HTMLTitle is html_title
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (unicode.IsUpper(runes[i-1]) && nextIsLower)) {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
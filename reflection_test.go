@@ -0,0 +1,181 @@
+package liquid
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type account struct {
+	Plan string
+}
+
+type session struct {
+	Token string
+}
+
+type user struct {
+	*account
+	FirstName string
+	Email     string `liquid:"email_address"`
+	Password  string
+	Secret    string `liquid:"-"`
+	Session   session
+	admin     bool
+}
+
+func (u user) Greeting() string { return "Hi " + u.FirstName }
+
+func (u *user) Promote() bool {
+	u.admin = true
+	return true
+}
+
+func (u *user) Reset() error {
+	u.Password = "reset"
+	return nil
+}
+
+func (u user) Name(ctx context.Context) (string, error) {
+	if ctx.Value(testContextKey) == nil {
+		return "", errors.New("no name")
+	}
+	return ctx.Value(testContextKey).(string), nil
+}
+
+func (u user) Greet(name string) string { return "Hi " + name }
+
+func (u user) Crash() string {
+	var m map[string]int
+	m["crash"]++
+	return ""
+}
+
+// apiKey is printable, so it's only hidden by denying its type
+type apiKey struct {
+	Key string
+}
+
+func (k apiKey) String() string { return k.Key }
+
+func TestReflectionPolicy(t *testing.T) {
+	u := &user{
+		account:   &account{Plan: "pro"},
+		FirstName: "Ada",
+		Email:     "ada@example.com",
+		Password:  "hunter2",
+		Secret:    "shh",
+		Session:   session{Token: "abc"},
+	}
+	vars := Vars{"user": u, "users": []*user{u}}
+
+	fields := ReflectionPolicy{Fields: true, DeniedFields: []string{"Password"}}
+	methods := ReflectionPolicy{Methods: true}
+	allowed := ReflectionPolicy{AllowedMethods: map[reflect.Type][]string{reflect.TypeOf(user{}): {"Greeting"}}}
+	denied := ReflectionPolicy{Fields: true, DeniedTypes: []reflect.Type{reflect.TypeOf(session{})}}
+
+	tests := []struct {
+		source string
+		policy *ReflectionPolicy
+		want   string
+	}{
+		// without a policy structs can't be looked up, or printed
+		{`{{ user.FirstName }}|{{ user }}`, nil, "|"},
+		{`{{ user.first_name }} {{ user.FirstName }} {{ user.plan }}`, &fields, "Ada Ada pro"},
+		{`{{ user.email_address }}|{{ user.email }}`, &fields, "ada@example.com|"},
+		{`{{ user.password }}{{ user.secret }}{{ user.Secret }}{{ user.admin }}`, &fields, ""},
+		{`{{ users.first.first_name }}`, &fields, "Ada"},
+		{`{% if user.first_name == 'Ada' %}yes{% endif %}`, &fields, "yes"},
+		{`{{ user.greeting }}`, &fields, ""},
+		{`{{ user.greeting }} {{ user.first_name }}`, &methods, "Hi Ada "},
+		{`{{ user.name }}|{{ user.greet }}`, &methods, "|"},
+		{`{{ user.reset }}`, &ReflectionPolicy{Fields: true, Methods: true}, ""},
+		{`{{ user.greeting }}|{{ user.promote }}`, &allowed, "Hi Ada|"},
+		{`{{ user.session.token }}|{{ user.session }}`, &denied, "|"},
+		{`{{ user.first_name }}`, &denied, "Ada"},
+	}
+
+	for _, test := range tests {
+		var opts []Option
+		if test.policy != nil {
+			opts = append(opts, WithReflectionPolicy(*test.policy))
+		}
+		tpl, err := ParseTemplate(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := tpl.Render(vars, opts...); err != nil || got != test.want {
+			t.Errorf("%v: want: %q, got: %q (%v)", test.source, test.want, got, err)
+		}
+	}
+
+	if u.admin || u.Password != "hunter2" {
+		t.Errorf("want methods which aren't allowed or only return an error not to be called")
+	}
+
+	// the policy applies to the array filters
+	tpl, _ := ParseTemplate(`{{ users | map: 'first_name' | join }}|{{ users | map: 'password' | join }}|{{ users | where: 'first_name', 'Ada' | size }}`)
+	if got, err := tpl.Render(vars, WithReflectionPolicy(fields)); err != nil || got != "Ada||1" {
+		t.Errorf("want: %q, got: %q (%v)", "Ada||1", got, err)
+	}
+	if _, err := tpl.Render(vars); !errors.Is(err, ArgumentError{}) {
+		t.Errorf("want structs not to have properties without a policy, got: %v", err)
+	}
+
+	// structs nested in hashes are never printed with their fields
+	data := Vars{"data": map[string]interface{}{
+		"user":   user{FirstName: "Ada", Password: "hunter2"},
+		"secret": apiKey{"k2"},
+		"tags":   []interface{}{"a", nil, 1.5},
+	}}
+	tpl, _ = ParseTemplate(`{{ data }}|{{ data | join }}`)
+	want := `{"secret"=>k2, "tags"=>["a", nil, 1.5], "user"=>#<liquid.user>}`
+	if got, err := tpl.Render(data); err != nil || got != want+"|"+want {
+		t.Errorf("want: %q, got: %q (%v)", want+"|"+want, got, err)
+	}
+	want = `{"secret"=>nil, "tags"=>["a", nil, 1.5], "user"=>#<liquid.user>}`
+	policy := WithReflectionPolicy(ReflectionPolicy{Fields: true, DeniedTypes: []reflect.Type{reflect.TypeOf(apiKey{})}})
+	if got, err := tpl.Render(data, policy); err != nil || got != want+"|"+want {
+		t.Errorf("want: %q, got: %q (%v)", want+"|"+want, got, err)
+	}
+
+	// methods which panic raise an error, which the error policy handles
+	tpl, _ = ParseTemplate(`a{{ user.crash }}b`)
+	if _, err := tpl.Render(vars, WithReflectionPolicy(methods)); !errors.Is(err, ArgumentError{}) {
+		t.Errorf("want an ArgumentError, got: %v", err)
+	}
+	got, err := tpl.Render(vars, WithReflectionPolicy(methods), WithInlineErrors())
+	if want := "aLiquid error (line 1): method 'crash' panicked: assignment to entry in nil mapb"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+
+	// methods are given the context of the render
+	tpl, _ = ParseTemplate(`{{ user.name }}`)
+	goctx := context.WithValue(context.Background(), testContextKey, "Ada")
+	if got, err := tpl.RenderContext(goctx, vars, WithReflectionPolicy(methods)); err != nil || got != "Ada" {
+		t.Errorf("want: %q, got: %q (%v)", "Ada", got, err)
+	}
+
+	// denied types are hidden even when they're Drops
+	tpl, _ = ParseTemplate(`{{ drop.name }}`)
+	denyDrop := ReflectionPolicy{DeniedTypes: []reflect.Type{reflect.TypeOf(userDrop{})}}
+	if got, err := tpl.RenderContext(goctx, Vars{"drop": userDrop{}}, WithReflectionPolicy(denyDrop)); err != nil || got != "" {
+		t.Errorf("want the drop to be hidden, got: %q (%v)", got, err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"FirstName": "first_name",
+		"ID":        "id",
+		"UserID":    "user_id",
+		"HTMLTitle": "html_title",
+		"Address2":  "address2",
+		"name":      "name",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("%v: want: %q, got: %q", name, want, got)
+		}
+	}
+}
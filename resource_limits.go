@@ -0,0 +1,103 @@
+package liquid
+
+// Resource limits from resource_limits.rb
+
+// The errors raised when a render goes over one of its ResourceLimits
+var (
+	ErrRenderLengthLimit = ResourceLimitError{Detail{Message: "Memory limits exceeded"}}
+	ErrRenderScoreLimit  = ResourceLimitError{Detail{Message: "Render score limits exceeded"}}
+	ErrAssignScoreLimit  = ResourceLimitError{Detail{Message: "Assign score limits exceeded"}}
+)
+
+// The errors raised when parsing a template goes over one of its ParseLimits
+var (
+	ErrSourceSizeLimit   = ResourceLimitError{Detail{Message: "Template size limits exceeded"}}
+	ErrTokenLimit        = ResourceLimitError{Detail{Message: "Token limits exceeded"}}
+	ErrMarkupLengthLimit = ResourceLimitError{Detail{Message: "Markup length limits exceeded"}}
+	ErrFilterLimit       = ResourceLimitError{Detail{Message: "Filter limits exceeded"}}
+)
+
+// ParseLimits bound the templates that can be parsed, so that untrusted sources
+// are turned away before much memory is spent on them. A limit of zero is unlimited
+type ParseLimits struct {
+	// SourceSize is the most bytes of template source
+	SourceSize int
+	// Tokens is the most tokens the source is split into, counting text, tags and variables
+	Tokens int
+	// MarkupLength is the most bytes of a single tag or variable, delimiters included
+	MarkupLength int
+	// Filters is the most filters a single variable can apply
+	Filters int
+}
+
+// exceeds reports whether n is over the limit
+func exceeds(n, limit int) bool {
+	return limit > 0 && n > limit
+}
+
+// ResourceLimits bound the work a single render can do, so that a template can't
+// use up the host's memory or time with a runaway loop. A limit of zero is unlimited
+type ResourceLimits struct {
+	// RenderLength is the most bytes of output
+	RenderLength int
+	// RenderScore is the most nodes rendered, each iteration of a block counts
+	RenderScore int
+	// AssignScore is the most bytes assigned to variables, see Context.Assign
+	AssignScore int
+}
+
+// resourceUsage tracks a render against its ResourceLimits
+type resourceUsage struct {
+	renderLength int
+	renderScore  int
+	assignScore  int
+}
+
+// render counts a node about to be rendered
+func (u *resourceUsage) render(limits ResourceLimits) error {
+	u.renderScore++
+	if limits.RenderScore > 0 && u.renderScore > limits.RenderScore {
+		return ErrRenderScoreLimit
+	}
+	return nil
+}
+
+// output sets the length of the output rendered so far
+func (u *resourceUsage) output(length int, limits ResourceLimits) error {
+	u.renderLength = length
+	if limits.RenderLength > 0 && u.renderLength > limits.RenderLength {
+		return ErrRenderLengthLimit
+	}
+	return nil
+}
+
+// assign counts a value assigned to a variable
+func (u *resourceUsage) assign(v interface{}, limits ResourceLimits) error {
+	u.assignScore += assignScore(interfaceToExpression(v))
+	if limits.AssignScore > 0 && u.assignScore > limits.AssignScore {
+		return ErrAssignScoreLimit
+	}
+	return nil
+}
+
+// assignScore is the analog to Utils.assign_score_of, strings count their
+// bytes, arrays and hashes their contents plus one, and anything else one
+func assignScore(e Expression) int {
+	switch v := e.(type) {
+	case stringExpr:
+		return len(v)
+	case arrayExpr:
+		score := 1
+		for _, item := range v {
+			score += assignScore(interfaceToExpression(item))
+		}
+		return score
+	case hashExpr:
+		score := 1
+		for k, item := range v {
+			score += len(k) + assignScore(interfaceToExpression(item))
+		}
+		return score
+	}
+	return 1
+}
@@ -0,0 +1,138 @@
+package liquid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// integration/template_test.rb resource limits
+
+func TestRenderLengthLimit(t *testing.T) {
+	tpl, err := ParseTemplate(`{% if true %}{% if true %}{{ text }}{% endif %}{% endif %}{{ text }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := Vars{"text": strings.Repeat("x", 10)}
+
+	// nested output is only counted once
+	if got, err := tpl.Render(vars, WithResourceLimits(ResourceLimits{RenderLength: 20})); err != nil || len(got) != 20 {
+		t.Errorf("want 20 bytes of output, got: %q (%v)", got, err)
+	}
+
+	for _, policy := range []ErrorPolicy{AbortOnError, RenderErrorsInline, CollectErrors} {
+		got, err := tpl.Render(vars, WithResourceLimits(ResourceLimits{RenderLength: 19}), WithErrorPolicy(policy))
+		if !errors.Is(err, ErrRenderLengthLimit) || !errors.Is(err, MemoryError{}) {
+			t.Errorf("want the memory limit to stop the render, got: %v", err)
+		}
+		if got != strings.Repeat("x", 10) {
+			t.Errorf("want the output before the limit, got: %q", got)
+		}
+	}
+
+	var located Error
+	_, err = tpl.Render(vars, WithResourceLimits(ResourceLimits{RenderLength: 5}))
+	if !errors.As(err, &located) || located.Column != 27 {
+		t.Errorf("want the position of the variable which went over the limit, got: %v", err)
+	}
+}
+
+func TestRenderScoreLimit(t *testing.T) {
+	tpl, err := ParseTemplate(`a{% if true %}b{{ c }}{% endif %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := tpl.Render(nil, WithResourceLimits(ResourceLimits{RenderScore: 4})); err != nil || got != "ab" {
+		t.Errorf("want: %q, got: %q (%v)", "ab", got, err)
+	}
+	if _, err := tpl.Render(nil, WithResourceLimits(ResourceLimits{RenderScore: 3}), WithInlineErrors()); !errors.Is(err, ErrRenderScoreLimit) {
+		t.Errorf("want the render score limit to stop the render, got: %v", err)
+	}
+
+	// the limits are per render
+	engine := NewEngine(WithResourceLimits(ResourceLimits{RenderScore: 4}))
+	tpl, _ = engine.ParseTemplate(`a{% if true %}b{{ c }}{% endif %}`)
+	for i := 0; i < 3; i++ {
+		if _, err := tpl.Render(nil); err != nil {
+			t.Errorf("render %v failed: %v", i, err)
+		}
+	}
+}
+
+func TestAssignScoreLimit(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		score int
+	}{
+		{"hello", 5},
+		{"", 0},
+		{1, 1},
+		{nil, 1},
+		{[]interface{}{"ab", 1}, 4},
+		{map[string]interface{}{"ab": []string{"cd"}}, 6},
+	}
+	for _, test := range tests {
+		if got := assignScore(interfaceToExpression(test.value)); got != test.score {
+			t.Errorf("assignScore(%v) want: %v, got: %v", test.value, test.score, got)
+		}
+	}
+
+	ctx := newContext()
+	ctx.scopes = scopeStack{Vars{}}
+	ctx.options.limits = ResourceLimits{AssignScore: 8}
+	if err := ctx.Assign("a", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Assign("b", "big"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Assign("c", "!"); !errors.Is(err, ErrAssignScoreLimit) {
+		t.Errorf("want the assign score limit, got: %v", err)
+	}
+	if _, err := ctx.Get("c"); err != ErrVarNotFound {
+		t.Errorf("the variable over the limit shouldn't be assigned")
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	source := "Hello {{ name | upcase | append: '!' }}\n{% if true %}{{ name }}{% endif %}"
+
+	tests := []struct {
+		limits ParseLimits
+		err    error
+		line   int
+		column int
+	}{
+		{ParseLimits{SourceSize: len(source), Tokens: 6, MarkupLength: 33, Filters: 2}, nil, 0, 0},
+		{ParseLimits{SourceSize: len(source) - 1}, ErrSourceSizeLimit, 1, 1},
+		{ParseLimits{Tokens: 5}, ErrTokenLimit, 1, 1},
+		{ParseLimits{MarkupLength: 32}, ErrMarkupLengthLimit, 1, 7},
+		{ParseLimits{Filters: 1}, ErrFilterLimit, 1, 7},
+	}
+
+	for _, test := range tests {
+		for _, opts := range [][]Option{{WithParseLimits(test.limits)}, {WithParseLimits(test.limits), WithAllErrors()}} {
+			_, err := ParseTemplate(source, opts...)
+			if test.err == nil {
+				if err != nil {
+					t.Errorf("%+v: want the template to parse, got: %v", test.limits, err)
+				}
+				continue
+			}
+
+			var located Error
+			if !errors.Is(err, test.err) || !errors.Is(err, ResourceLimitError{}) || !errors.As(err, &located) {
+				t.Errorf("%+v: want %v, got: %v", test.limits, test.err, err)
+			} else if located.Line != test.line || located.Column != test.column {
+				t.Errorf("%+v: want line %v, column %v, got: %v", test.limits, test.line, test.column, located.Position)
+			}
+		}
+	}
+
+	// the engine's limits apply to all of its templates
+	engine := NewEngine(WithParseLimits(ParseLimits{Tokens: 1}))
+	if _, err := engine.ParseTemplate(source); !errors.Is(err, ErrTokenLimit) {
+		t.Errorf("want the engine's limits to apply, got: %v", err)
+	}
+}
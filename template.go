@@ -1,6 +1,8 @@
 package liquid
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -21,22 +23,61 @@ const (
 )
 
 // Template is a parsed liquid string containing a list
-// of Nodes that can be used to render an output
+// of Nodes that can be used to render an output.
+//
+// A Template isn't changed once it has been parsed, so it can be rendered by many
+// goroutines at once. Everything a render changes, such as its variables, resource
+// usage and registers, is kept in the Context of that render
 type Template struct {
-	Nodes []Node
+	Nodes    []Node
+	name     string
+	source   string
+	engine   *Engine
+	errors   []error
+	warnings []error
+}
+
+// Errors are the errors found while parsing with WithAllErrors, the nodes
+// which raised them are left out of the template
+func (t *Template) Errors() []error {
+	return t.errors
+}
+
+// Warnings are the errors of markup which was parsed laxly in WarnMode
+func (t *Template) Warnings() []error {
+	return t.warnings
+}
+
+// errorAt adds the template's name and the position in its source to an error
+func (t *Template) errorAt(err error, pos Position) error {
+	if l, ok := err.(locatable); ok {
+		err = l.at(pos)
+	}
+	return Error{
+		Template: t.name,
+		Position: pos,
+		Err:      err,
+		source:   sourceLine(t.source, pos.Line),
+	}
 }
 
 // Node must be implemented by all parts of a template, and
 // provides the necessary rendering handlers to allow generating
 // a final output
 type Node interface {
-	Render(Vars) (string, error)
+	Render(*Context) (string, error)
 	Blank() bool
 }
 
+// positioned is implemented by the Nodes that know where they are in the template,
+// so that errors raised while rendering them can be given a position
+type positioned interface {
+	Position() Position
+}
+
 type stringNode string
 
-func (n stringNode) Render(v Vars) (string, error) {
+func (n stringNode) Render(ctx *Context) (string, error) {
 	return string(n), nil
 }
 
@@ -54,25 +95,36 @@ type commentTag struct{}
 
 func (t *commentTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
 
-	subctx := &ParseContext{
-		line: ctx.line,
-		end:  fmt.Sprintf("end%v", name),
-	}
+	subctx := ctx.nested(fmt.Sprintf("end%v", name), nil)
+	// commented out code isn't used, so it isn't checked for unknown filters
+	subctx.options.strictFilters = false
 
+	pos := ctx.Position()
 	nodelist, err := tokensToNodeList(tokenizer, subctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx.line = subctx.line
-
 	return BlockNode{
 		Tag:    name,
+		tag:    t,
 		markup: markup,
 		Nodes:  nodelist,
+		pos:    pos,
 	}, nil
 }
 
+// Comments never produce any output
+func (t *commentTag) renderBlock(n BlockNode, ctx *Context) (string, error) {
+	return "", nil
+}
+
+// blockRenderer can be implemented by a Tag to control how the BlockNodes
+// it creates are rendered. By default a BlockNode renders all of its Nodes
+type blockRenderer interface {
+	renderBlock(n BlockNode, ctx *Context) (string, error)
+}
+
 type elseTag struct {
 	Params bool
 }
@@ -80,64 +132,93 @@ type elseTag struct {
 func (t *elseTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
 	if !t.Params {
 		if strings.Replace(markup, " ", "", -1) != "{%else%}" {
-			return nil, errors.New("else doesn't accept params")
+			return nil, syntaxError("else doesn't accept params")
 		}
 	}
-	return elseNode{tag: name, markup: markup}, nil
+	return elseNode{tag: name, markup: markup, pos: ctx.Position()}, nil
 }
 
-// Conditional tag
-type ifTag struct{}
+// RegisterTag makes a tag available to the templates parsed with ParseTemplate,
+// registering an existing name replaces that tag. It registers the tag on the
+// default engine, other engines don't see it, see Engine.RegisterTag
+func RegisterTag(name string, tag Tag) {
+	defaultEngine.RegisterTag(name, tag)
+}
 
-func (t *ifTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
+// standardTags are the tags every engine has, they're never changed
+var standardTags = map[string]Tag{
+	"comment": &commentTag{},
+	"if":      &ifTag{},
+	"unless":  &ifTag{Unless: true},
+}
 
-	subctx := &ParseContext{
-		line: ctx.line,
-		end:  fmt.Sprintf("end%v", name),
-		temporaryTags: map[string]Tag{
-			"elsif": &elseTag{Params: true},
-			"else":  &elseTag{},
-		},
-	}
+type ParseContext struct {
+	pos           Position
+	depth         int
+	end           string
+	temporaryTags map[string]Tag
+	options       options
+	template      *Template
+	engine        *Engine
+	problems      *parseProblems
+}
 
-	nodelist, err := tokensToNodeList(tokenizer, subctx)
-	if err != nil {
-		return nil, err
-	}
+var errNestingTooDeep = StackLevelError{Detail{Message: "Nesting too deep"}}
 
-	ctx.line = subctx.line
+// parseProblems collects the errors and warnings of a parse, it's shared by the nested contexts
+type parseProblems struct {
+	errors   []error
+	warnings []error
+}
 
-	return BlockNode{
-		Tag:    name,
-		Nodes:  nodelist,
-		markup: markup,
-	}, nil
+// nested creates the context for parsing the body of a block, which ends with
+// the end tag and can contain the temporary tags (else, when etc.)
+func (c *ParseContext) nested(end string, temporaryTags map[string]Tag) *ParseContext {
+	return &ParseContext{
+		pos:           c.pos,
+		depth:         c.depth + 1,
+		end:           end,
+		temporaryTags: temporaryTags,
+		options:       c.options,
+		template:      c.template,
+		engine:        c.engine,
+		problems:      c.problems,
+	}
 }
 
-// RegisterTag registers a new tag (big surprise)
-// and probably needs a mutex?
-func RegisterTag(name string, tag Tag) {
-	RegisteredTags[name] = tag
+// Position returns where the token being parsed starts in the template, so tags
+// can keep the position of the nodes they create
+func (c *ParseContext) Position() Position {
+	return c.pos
 }
 
-// RegisteredTags are all known tags
-var RegisteredTags = map[string]Tag{
-	"comment": &commentTag{},
-	"if":      &ifTag{},
+// errorAt gives an error the position of the token being parsed, unless it
+// already has the position of a token nested in it
+func (c *ParseContext) errorAt(err error) error {
+	var located Error
+	if errors.As(err, &located) {
+		return err
+	}
+	return c.template.errorAt(err, c.pos)
 }
 
-type ParseContext struct {
-	line          int
-	end           string
-	temporaryTags map[string]Tag
+// warn records a warning for the token being parsed
+func (c *ParseContext) warn(err error) {
+	c.problems.warnings = append(c.problems.warnings, c.errorAt(err))
 }
 
 func (c *ParseContext) String() string {
-	return fmt.Sprintf("Line: %v, End: %v", c.line, c.end)
+	return fmt.Sprintf("%v, End: %v", c.pos, c.end)
 }
 
 func tokensToNodeList(tokenizer *Tokenizer, ctx *ParseContext) ([]Node, error) {
+	if ctx.depth > depthLimit(ctx.options.nestingLimit) {
+		return nil, ctx.errorAt(errNestingTooDeep)
+	}
+
 	var nodeList []Node
+	// nested blocks start out at the position of their tag
+	start := ctx.pos
 
 	blank := true
 
@@ -151,88 +232,253 @@ func tokensToNodeList(tokenizer *Tokenizer, ctx *ParseContext) ([]Node, error) {
 			continue
 		}
 
-		switch {
-		case strings.HasPrefix(token, tagStartToken):
-			if matched := fullTokenRegexp.FindStringSubmatch(token); len(matched) > 0 {
-				markup, tagName := matched[0], matched[1]
-				// Check for end tag
-				if strings.HasPrefix(tagName, "end") {
-					var err error
-					if tagName != ctx.end {
-						err = LiquidError(fmt.Sprintf("Unexpected end tag: %v, %v", tagName, markup), ctx)
-					}
+		ctx.pos = tokenizer.Position()
+		next := tokenizer.index
+		node, end, err := parseToken(token, tokenizer, ctx)
+		if err != nil {
+			err = ctx.errorAt(err)
+			// going over a limit always stops the parse
+			if !ctx.options.allErrors || errors.Is(err, ResourceLimitError{}) {
+				if end {
 					return nodeList, err
-				} else if tag, ok := RegisteredTags[tagName]; ok {
-					newTag, err := tag.Parse(tagName, markup, tokenizer, ctx)
-					if err != nil {
-						return nil, err
-					}
-					blank = blank && newTag.Blank()
-					nodeList = append(nodeList, newTag)
-				} else if tag, ok := ctx.temporaryTags[tagName]; ok {
-					newTag, err := tag.Parse(tagName, markup, tokenizer, ctx)
-					if err != nil {
-						return nil, err
-					}
-					blank = blank && newTag.Blank()
-					nodeList = append(nodeList, newTag)
-				} else if tagName == "else" || tagName == "end" {
-					return nil, ErrSyntax("Unexpected outer 'else' tag")
-				} else {
-					return nil, ErrSyntax(fmt.Sprintf("Unknown tag '%v'", tagName))
 				}
-			} else {
-				return nil, LiquidError(fmt.Sprintf("Missing tag terminator: %v", token), ctx)
-			}
-
-		case strings.HasPrefix(token, varStartToken):
-			myVar, err := createVariable(token, ctx)
-			if err != nil {
 				return nil, err
 			}
-			nodeList = append(nodeList, myVar)
-			blank = false
-		default:
-			nodeList = append(nodeList, stringNode(token))
-			blank = blank && tokenIsBlankRegexp.MatchString(token)
+			// skip the token and carry on from the next one. A tag which failed before
+			// parsing its body has the rest of its block skipped too, up to its end tag
+			ctx.problems.errors = append(ctx.problems.errors, err)
+			if !end && tokenizer.index == next {
+				skipBlock(tokenizer, token)
+			}
+			continue
+		}
+		if end {
+			return nodeList, nil
+		}
+
+		if s, ok := node.(stringNode); ok {
+			blank = blank && tokenIsBlankRegexp.MatchString(string(s))
+		} else {
+			blank = blank && node.Blank()
 		}
-		ctx.line += strings.Count(token, "\n")
+		nodeList = append(nodeList, node)
 	}
 
+	// the template ended before the block's end tag
+	if ctx.end != "" {
+		return nil, ctx.template.errorAt(syntaxError("'%v' tag was never closed", strings.TrimPrefix(ctx.end, "end")), start)
+	}
 	return nodeList, nil
 }
 
-// ParseTemplate performs the parsing step from Liquid::BlockBody.parse
-func ParseTemplate(template string) (*Template, error) {
+// skipBlock moves the tokenizer past the end tag matching a tag token, so the body of
+// a block which couldn't be parsed isn't parsed as if it were outside of it. Nothing is
+// skipped when there's no end tag, as the tag may not be a block at all
+func skipBlock(tokenizer *Tokenizer, token string) {
+	matched := fullTokenRegexp.FindStringSubmatch(token)
+	if !strings.HasPrefix(token, tagStartToken) || len(matched) == 0 {
+		return
+	}
+	name := matched[1]
+
+	depth := 0
+	for i := tokenizer.index; i < len(tokenizer.tokens); i++ {
+		if !strings.HasPrefix(tokenizer.tokens[i], tagStartToken) {
+			continue
+		}
+		matched := fullTokenRegexp.FindStringSubmatch(tokenizer.tokens[i])
+		if len(matched) == 0 {
+			continue
+		}
+		switch matched[1] {
+		case name:
+			depth++
+		case "end" + name:
+			if depth == 0 {
+				tokenizer.index = i + 1
+				return
+			}
+			depth--
+		}
+	}
+}
+
+// parseToken creates the Node for a token, or reports that the token is an end tag
+func parseToken(token string, tokenizer *Tokenizer, ctx *ParseContext) (Node, bool, error) {
+	if exceeds(len(token), ctx.options.parseLimits.MarkupLength) && !isText(token) {
+		return nil, false, ErrMarkupLengthLimit
+	}
+
+	switch {
+	case strings.HasPrefix(token, tagStartToken):
+		matched := fullTokenRegexp.FindStringSubmatch(token)
+		if len(matched) == 0 {
+			return nil, false, syntaxError("Missing tag terminator: %v", token)
+		}
+
+		markup, tagName := matched[0], matched[1]
+		// Check for end tag
+		if strings.HasPrefix(tagName, "end") {
+			if tagName != ctx.end {
+				return nil, true, syntaxError("Unexpected end tag: %v, %v", tagName, markup)
+			}
+			return nil, true, nil
+		} else if tag, ok := ctx.engine.orDefault().findTag(tagName); ok {
+			if err := ctx.checkTag(tagName, strings.TrimSpace(matched[2])); err != nil {
+				return nil, false, err
+			}
+			node, err := tag.Parse(tagName, markup, tokenizer, ctx)
+			return node, false, err
+		} else if tag, ok := ctx.temporaryTags[tagName]; ok {
+			node, err := tag.Parse(tagName, markup, tokenizer, ctx)
+			return node, false, err
+		} else if tagName == "else" || tagName == "end" {
+			return nil, false, syntaxError("Unexpected outer 'else' tag")
+		}
+		return nil, false, syntaxError("Unknown tag '%v'", tagName)
+
+	case strings.HasPrefix(token, varStartToken):
+		node, err := createVariable(token, ctx)
+		return node, false, err
+	}
+
+	return stringNode(token), false, nil
+}
+
+// checkTag checks that the template may use a tag, see WithAllowedTags and WithTagCheck
+func (c *ParseContext) checkTag(name, markup string) error {
+	if !permitted(name, c.options.allowedTags, c.options.deniedTags) {
+		return syntaxError("Tag '%v' is not allowed", name)
+	}
+	if c.options.tagCheck != nil {
+		if err := c.options.tagCheck(name, markup); err != nil {
+			if _, ok := err.(SyntaxError); !ok {
+				err = SyntaxError{Detail{Err: err}}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// isText reports whether a token is text, rather than a tag or variable
+func isText(token string) bool {
+	return !strings.HasPrefix(token, tagStartToken) && !strings.HasPrefix(token, varStartToken)
+}
+
+// ParseTemplate performs the parsing step from Liquid::BlockBody.parse. Options that
+// affect parsing, like WithStrictFilters, are applied, the rest are ignored
+func ParseTemplate(template string, opts ...Option) (*Template, error) {
+	return defaultEngine.ParseTemplate(template, opts...)
+}
+
+func parseTemplate(template string, engine *Engine, opts []Option) (*Template, error) {
+	t := &Template{source: template, engine: engine}
+	ctx := &ParseContext{template: t, engine: engine, problems: &parseProblems{}}
+	for _, opt := range opts {
+		opt(&ctx.options)
+	}
+	t.name = ctx.options.name
+
+	// untrusted sources are turned away before they're tokenized
+	if exceeds(len(template), ctx.options.parseLimits.SourceSize) {
+		return t, t.errorAt(ErrSourceSizeLimit, Position{Line: 1, Column: 1})
+	}
 
 	// tokenize the source
-	tokenizer := NewTokenizer(template)
-	ctx := &ParseContext{line: 0}
+	tokenizer, err := newTokenizer(template, ctx.options.parseLimits.Tokens)
+	if err != nil {
+		return t, t.errorAt(err, Position{Line: 1, Column: 1})
+	}
 	nodeList, err := tokensToNodeList(tokenizer, ctx)
+	if len(ctx.problems.errors) > 0 {
+		err = ctx.problems.errors[0]
+	}
+
+	t.Nodes = nodeList
+	t.errors = ctx.problems.errors
+	t.warnings = ctx.problems.warnings
+	return t, err
+}
 
-	return &Template{nodeList}, err
+// Render the template with the supplied variables. How errors raised by the
+// template's nodes are handled depends on the ErrorPolicy, by default the
+// render stops at the first one
+func (t *Template) Render(vars Vars, opts ...Option) (string, error) {
+	return t.RenderContext(context.Background(), vars, opts...)
 }
 
-// Render the template with the supplied variables
-func (t *Template) Render(vars Vars) (string, error) {
-	if len(t.Nodes) == 0 || t.Nodes[0].Blank() {
-		return "", nil
+// RenderContext renders the template like Render, stopping with the context's
+// error when it is cancelled or its deadline expires. The context is checked
+// before each node is rendered, and passed on to filters, Drops and tags
+func (t *Template) RenderContext(goctx context.Context, vars Vars, opts ...Option) (string, error) {
+	if vars == nil {
+		vars = Vars{}
+	}
+	ctx := newContext()
+	ctx.goctx = goctx
+	// variables are assigned in a scope of their own, so the vars
+	// can be shared by renders running at the same time
+	ctx.scopes = scopeStack{vars, Vars{}}
+	ctx.template = t
+	ctx.engine = t.engine
+	if t.engine != nil {
+		opts = t.engine.withOptions(opts)
+	}
+	for _, opt := range opts {
+		opt(&ctx.options)
 	}
 
-	// Obviously we need to actually render the rest of the Nodes.
-	return t.Nodes[0].Render(vars)
+	output, err := renderNodes(t.Nodes, &ctx)
+	if err == nil && len(ctx.errors) > 0 {
+		err = ctx.errors
+	}
+	return output, err
 }
 
-//     def render_node(node, context)
-//       node_output = (node.respond_to?(:render) ? node.render(context) : node)
-//       node_output = node_output.is_a?(Array) ? node_output.join : node_output.to_s
+// renderNodes renders each node in turn, the analog to BlockBody#render. The
+// output of the nodes is checked against the render's ResourceLimits
+func renderNodes(nodes []Node, ctx *Context) (string, error) {
+	ctx.depth++
+	defer func() { ctx.depth-- }()
+	if ctx.depth > depthLimit(ctx.options.recursionLimit) {
+		return "", errNestingTooDeep
+	}
 
-//       context.resource_limits.render_length += node_output.length
-//       if context.resource_limits.reached?
-//         raise MemoryError.new("Memory limits exceeded".freeze)
-//       end
-//       node_output
-//     end
+	var output bytes.Buffer
+	for _, node := range nodes {
+		if err := ctx.Context().Err(); err != nil {
+			return output.String(), ctx.abort(ctx.errorAt(err, node))
+		}
+		if err := ctx.resources.render(ctx.options.limits); err != nil {
+			return output.String(), ctx.abort(ctx.errorAt(err, node))
+		}
+
+		before := ctx.resources.renderLength
+		nodeOutput, err := node.Render(ctx)
+		if err != nil {
+			// errors which abort the render have already been through the policy
+			if ctx.aborted != nil {
+				return output.String(), ctx.aborted
+			}
+			// going over a limit always stops the render
+			if errors.Is(err, ResourceLimitError{}) {
+				return output.String(), ctx.abort(ctx.errorAt(err, node))
+			}
+			if nodeOutput, err = ctx.handleError(ctx.errorAt(err, node)); err != nil {
+				return output.String(), err
+			}
+		}
+
+		// the output of nested nodes has been counted while rendering them, but
+		// only the node's own output is kept
+		if err := ctx.resources.output(before+len(nodeOutput), ctx.options.limits); err != nil {
+			return output.String(), ctx.abort(ctx.errorAt(err, node))
+		}
+		output.WriteString(nodeOutput)
+	}
+	return output.String(), nil
+}
 
 //     def create_variable(token, parse_context)
 //       token.scan(ContentOfVariable) do |content|
@@ -242,43 +488,83 @@ func (t *Template) Render(vars Vars) (string, error) {
 //       raise_missing_variable_terminator(token, parse_context)
 //     end
 
+// tagMarkup strips the delimiters and tag name from a full tag token,
+// leaving only the markup that follows the name
+func tagMarkup(token string) string {
+	if matched := fullTokenRegexp.FindStringSubmatch(token); len(matched) > 2 {
+		return matched[2]
+	}
+	return ""
+}
+
 func createVariable(token string, ctx *ParseContext) (Node, error) {
 	parsed := contentOfVariableRegexp.FindStringSubmatch(token)
 
 	if len(parsed) != 2 {
-		return nil, errors.New("no variable content")
+		return nil, syntaxError("Variable '%v' was not properly terminated", token)
 	}
 
-	v, err := CreateVariable(parsed[1])
+	v, err := parseVariable(parsed[1], ctx)
 	if err != nil {
 		return nil, err
 	}
+	v.pos = ctx.Position()
+
+	if exceeds(len(v.Filters), ctx.options.parseLimits.Filters) {
+		return nil, ErrFilterLimit
+	}
+	for _, filter := range v.Filters {
+		if !permitted(filter.name, ctx.options.allowedFilters, ctx.options.deniedFilters) {
+			return nil, syntaxError("Filter '%v' is not allowed", filter.name)
+		}
+	}
+	if ctx.options.strictFilters {
+		for _, filter := range v.Filters {
+			if _, ok := ctx.engine.orDefault().findFilter(filter.name); !ok {
+				return nil, UndefinedFilter{Name: filter.name}
+			}
+		}
+	}
 	return v, nil
 }
 
 type BlockNode struct {
-	Tag    string
+	Tag string
+	// tag is the Tag which parsed the block, so it's rendered by the same tag
+	// even if another is registered under its name later
+	tag    Tag
 	markup string
 	Nodes  []Node
+	pos    Position
 }
 
-func (n BlockNode) Render(v Vars) (string, error) {
-	panic("unimplemented")
+// Position returns where the block's tag starts in the template
+func (n BlockNode) Position() Position {
+	return n.pos
+}
+
+func (n BlockNode) Render(ctx *Context) (string, error) {
+	if renderer, ok := n.tag.(blockRenderer); ok {
+		return renderer.renderBlock(n, ctx)
+	}
+	return renderNodes(n.Nodes, ctx)
 }
 
 func (n BlockNode) Blank() bool {
 	return len(n.Nodes) == 0
 }
 
-// XXX: This is just to make it possible to parse if/else blocks. Clearly this structure doesn't actually work
+// elseNode marks the boundary between the blocks of a conditional tag.
+// They're only produced while parsing, and are removed by the tag that owns them
 type elseNode struct {
 	tag    string
 	markup string
-	params []string
+	// pos is where the tag starts, for the errors in an elsif's condition
+	pos Position
 }
 
-func (n elseNode) Render(v Vars) (string, error) {
-	panic("unimplemented")
+func (n elseNode) Render(ctx *Context) (string, error) {
+	return "", nil
 }
 
 func (n elseNode) Blank() bool {
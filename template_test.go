@@ -1,8 +1,14 @@
 package liquid
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func checkTemplate(t *testing.T, tpl string, want []Node) {
@@ -23,7 +29,7 @@ func TestBlankSpace(t *testing.T) {
 
 func TestVariableBeginning(t *testing.T) {
 	checkTemplate(t, "{{funk}}  ", []Node{
-		testVariableNode("funk"),
+		testVariableNode("funk", 1, 1),
 		stringNode("  "),
 	})
 }
@@ -31,14 +37,14 @@ func TestVariableBeginning(t *testing.T) {
 func TestVariableEnd(t *testing.T) {
 	checkTemplate(t, "  {{funk}}", []Node{
 		stringNode("  "),
-		testVariableNode("funk"),
+		testVariableNode("funk", 1, 3),
 	})
 }
 
 func TestVariableMiddle(t *testing.T) {
 	checkTemplate(t, "  {{funk}}  ", []Node{
 		stringNode("  "),
-		testVariableNode("funk"),
+		testVariableNode("funk", 1, 3),
 		stringNode("  "),
 	})
 }
@@ -46,11 +52,11 @@ func TestVariableMiddle(t *testing.T) {
 func TestVariableManyEmbeddedFragments(t *testing.T) {
 	checkTemplate(t, "  {{funk}} {{so}} {{brother}} ", []Node{
 		stringNode("  "),
-		testVariableNode("funk"),
+		testVariableNode("funk", 1, 3),
 		stringNode(" "),
-		testVariableNode("so"),
+		testVariableNode("so", 1, 12),
 		stringNode(" "),
-		testVariableNode("brother"),
+		testVariableNode("brother", 1, 19),
 		stringNode(" "),
 	})
 }
@@ -60,8 +66,10 @@ func TestWithBlock(t *testing.T) {
 		stringNode("  "),
 		BlockNode{
 			Tag:    "comment",
+			tag:    &commentTag{},
 			markup: "{% comment %}",
 			Nodes:  []Node{stringNode(" ")},
+			pos:    Position{Line: 1, Column: 3},
 		},
 		stringNode(" "),
 	})
@@ -72,42 +80,492 @@ func TestWithCustomTag(t *testing.T) {
 	checkTemplate(t, `{% testtag %} {% endtesttag %}`, []Node{
 		BlockNode{
 			Tag:    "testtag",
+			tag:    &commentTag{},
 			markup: "{% testtag %}",
 			Nodes:  []Node{stringNode(" ")},
+			pos:    Position{Line: 1, Column: 1},
 		},
 	})
 }
 
 func TestVariableLookup(t *testing.T) {
 	checkTemplate(t, `{{a.b.first}}<br>{{c.d[0]}}<br>{{e.f | first}}`, []Node{
-		testVariableNode("a.b.first"),
+		testVariableNode("a.b.first", 1, 1),
 		stringNode("<br>"),
-		testVariableNode("c.d[0]"),
+		testVariableNode("c.d[0]", 1, 18),
 		stringNode("<br>"),
-		testVariableNode("e.f | first"),
+		testVariableNode("e.f | first", 1, 32),
 	})
 }
 
 func TestParseIfBlock(t *testing.T) {
 	checkTemplate(t, `{% if x > 100 %}Huge{% elsif x > 10 %}Big{% else %}Normal{% endif %}`, []Node{
-		BlockNode{
-			Tag:    "if",
+		ifNode{
+			tag:    "if",
 			markup: "{% if x > 100 %}",
-			Nodes: []Node{
-				stringNode("Huge"),
-				elseNode{tag: "elsif", markup: "{% elsif x > 10 %}"},
-				stringNode("Big"),
-				elseNode{tag: "else", markup: "{% else %}"},
-				stringNode("Normal"),
+			blocks: []conditionalBlock{
+				{
+					condition: &Condition{a: ParseVariableLookup("x"), operator: ">", b: integerExpr(100)},
+					nodes:     []Node{stringNode("Huge")},
+				},
+				{
+					condition: &Condition{a: ParseVariableLookup("x"), operator: ">", b: integerExpr(10)},
+					nodes:     []Node{stringNode("Big")},
+				},
+				{
+					nodes: []Node{stringNode("Normal")},
+				},
 			},
+			pos: Position{Line: 1, Column: 1},
 		},
 	})
 }
 
-func testVariableNode(v string) Node {
+func testVariableNode(v string, line, column int) Node {
 	variable, err := CreateVariable(v)
 	if err != nil {
 		return nil
 	}
+	variable.pos = Position{Line: line, Column: column}
 	return variable
 }
+
+func TestErrorModes(t *testing.T) {
+	source := `{{ name | upcase arg }} {% if true %}{{ name | | downcase }}{% endif %}`
+	vars := Vars{"name": "Liquid"}
+
+	if _, err := ParseTemplate(source); err == nil {
+		t.Error("strict mode should have failed to parse")
+	}
+	if _, err := ParseTemplate(source, WithErrorMode(StrictMode)); err == nil {
+		t.Error("strict mode should have failed to parse")
+	}
+
+	tpl, err := ParseTemplate(source, WithErrorMode(LaxMode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(vars); err != nil || got != "LIQUID liquid" {
+		t.Errorf("want: %q, got: %q (%v)", "LIQUID liquid", got, err)
+	}
+	if len(tpl.Warnings()) != 0 {
+		t.Errorf("lax mode shouldn't warn, got: %v", tpl.Warnings())
+	}
+
+	tpl, err = ParseTemplate(source, WithErrorMode(WarnMode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(vars); err != nil || got != "LIQUID liquid" {
+		t.Errorf("want: %q, got: %q (%v)", "LIQUID liquid", got, err)
+	}
+	warnings := tpl.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("want 2 warnings, got: %v", warnings)
+	}
+	var syntaxErr SyntaxError
+	if !errors.As(warnings[0], &syntaxErr) || !strings.HasSuffix(syntaxErr.Message, `in "{{ name | upcase arg }}"`) {
+		t.Errorf("unexpected warning: %v", warnings[0])
+	}
+
+	// markup with nothing to look up renders as nil rather than panicking
+	for _, source := range []string{`{{ ... }}`, `{{ ( }}`, `{{ [ }}`, `{{ a | append: ... }}`, `{{ a | append: [] }}`} {
+		for _, mode := range []ErrorMode{LaxMode, WarnMode} {
+			tpl, err := ParseTemplate(source, WithErrorMode(mode))
+			if err != nil {
+				t.Errorf("%v: want the template to parse, got: %v", source, err)
+				continue
+			}
+			if got, err := tpl.Render(Vars{"a": "a"}); err != nil || (got != "" && got != "a") {
+				t.Errorf("%v: want nil output, got: %q (%v)", source, got, err)
+			}
+		}
+	}
+
+	engine := NewEngine(WithErrorMode(LaxMode))
+	if _, err := engine.ParseTemplate(source); err != nil {
+		t.Errorf("engine in lax mode failed to parse: %v", err)
+	}
+	if _, err := engine.ParseTemplate(source, WithErrorMode(StrictMode)); err == nil {
+		t.Error("strict mode should override the engine's lax mode")
+	}
+}
+
+func TestUnclosedBlocks(t *testing.T) {
+	tests := []struct {
+		source string
+		tag    string
+		column int
+	}{
+		{`{% if a %}x`, "if", 1},
+		{`{% unless a %}x`, "unless", 1},
+		{`x{% comment %}x`, "comment", 2},
+		{`{% if a %}x{% if b %}y{% endif %}`, "if", 1},
+	}
+	for _, test := range tests {
+		_, err := ParseTemplate(test.source)
+		var located Error
+		message := fmt.Sprintf("'%v' tag was never closed", test.tag)
+		if !errors.Is(err, SyntaxError{Detail{Message: message}}) || !errors.As(err, &located) || located.Column != test.column {
+			t.Errorf("%v: want %q at column %v, got: %v", test.source, message, test.column, err)
+		}
+	}
+
+	// it's reported along with the other errors
+	tpl, _ := ParseTemplate("{% nope %}\n{% if a %}x", WithAllErrors())
+	if errs := tpl.Errors(); len(errs) != 2 || !errors.Is(errs[1], SyntaxError{Detail{Message: "'if' tag was never closed"}}) {
+		t.Errorf("want the unclosed block to be reported, got: %v", errs)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	source := "{{ a | | b }}\n" +
+		"{% if a %}\n" +
+		"  {% nope %}\n" +
+		"  {{ a }}\n" +
+		"{% endif %}\n" +
+		"{% if a b %}{{ a }}{% endif %}\n" +
+		"{{ b }}{% endfor %}\n" +
+		"{{ c | ! }}"
+
+	if _, err := ParseTemplate(source); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	tpl, err := ParseTemplate(source, WithAllErrors())
+	if err == nil {
+		t.Error("expected the first error to be returned")
+	}
+	if tpl == nil {
+		t.Fatal("expected a partial template")
+	}
+
+	var lines []int
+	for _, err := range tpl.Errors() {
+		var parseErr Error
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%v isn't an Error", err)
+		}
+		lines = append(lines, parseErr.Line)
+	}
+	if want := []int{1, 3, 6, 7, 8}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("errors on the wrong lines, want: %v, got: %v (%v)", want, lines, tpl.Errors())
+	}
+	if err != tpl.Errors()[0] {
+		t.Errorf("want the first error, got: %v", err)
+	}
+
+	if got, err := tpl.Render(Vars{"a": 1, "b": 2}); err != nil || got != "\n\n  \n  1\n\n\n2\n" {
+		t.Errorf("unexpected render of the partial template: %q (%v)", got, err)
+	}
+
+	// blocks whose tag fails are skipped up to their end tag, which isn't another error
+	tpl, _ = ParseTemplate("{% if a %}{% if b %}x{% endif %}{% endif %}{% nope %}{{ a | | b }}{% endnope %}y{% nope %}z", WithAllErrors(), WithDeniedTags("if"))
+	var messages []string
+	for _, err := range tpl.Errors() {
+		var syntaxErr SyntaxError
+		if errors.As(err, &syntaxErr) {
+			messages = append(messages, syntaxErr.message())
+		}
+	}
+	if want := []string{"Tag 'if' is not allowed", "Unknown tag 'nope'", "Unknown tag 'nope'"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("want errors: %q, got: %q", want, messages)
+	}
+	if got, err := tpl.Render(Vars{"a": 1, "b": 2}); err != nil || got != "yz" {
+		t.Errorf("want: %q, got: %q (%v)", "yz", got, err)
+	}
+
+	tpl, err = ParseTemplate("{{ a | | b }}\n{{ a }}", WithAllErrors(), WithErrorMode(WarnMode))
+	if err != nil || len(tpl.Errors()) != 0 || len(tpl.Warnings()) != 1 {
+		t.Errorf("warnings shouldn't be errors, got: %v %v (%v)", tpl.Errors(), tpl.Warnings(), err)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	source := "Hello\n{% if a %}\n\t{{ a | | b }}\n{% endif %}"
+	_, err := ParseTemplate(source, WithTemplateName("greeting.liquid"))
+
+	var located Error
+	if !errors.As(err, &located) {
+		t.Fatalf("want an Error, got: %v", err)
+	}
+	if located.Template != "greeting.liquid" || located.Position != (Position{Line: 3, Column: 2}) {
+		t.Errorf("wrong position: %v", located)
+	}
+	if want := "greeting.liquid:3:2: " + located.Err.Error(); err.Error() != want {
+		t.Errorf("want: %q, got: %q", want, err.Error())
+	}
+	if want := "3 | \t{{ a | | b }}\n  | \t^"; located.Excerpt() != want {
+		t.Errorf("want excerpt: %q, got: %q", want, located.Excerpt())
+	}
+
+	// errors in an elsif's condition are at the elsif, not the if
+	source = "{% if a %}\n  a\n  {% elsif b c %}\n  b\n{% endif %}"
+	_, err = ParseTemplate(source)
+	if !errors.As(err, &located) || located.Position != (Position{Line: 3, Column: 3}) {
+		t.Errorf("want an error at line 3, column 3, got: %v", err)
+	}
+	tpl, _ := ParseTemplate("{% if a b %}\n{% elsif %}\n{% endif %}\n{% unless a %}{% elsif b b %}{% endunless %}", WithAllErrors())
+	var positions []Position
+	for _, err := range tpl.Errors() {
+		if errors.As(err, &located) {
+			positions = append(positions, located.Position)
+		}
+	}
+	if want := []Position{{Line: 1, Column: 1}, {Line: 4, Column: 15}}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("want errors at: %v, got: %v (%v)", want, positions, tpl.Errors())
+	}
+
+	tpl, err = ParseTemplate("{% if true %}\n  ok {{ 1 | divided_by: 0 }}\n{% endif %}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpl.Render(nil)
+	if !errors.As(err, &located) || located.Position != (Position{Line: 2, Column: 6}) {
+		t.Errorf("render error at the wrong position: %v", err)
+	}
+	if !errors.Is(err, ZeroDivisionError{}) {
+		t.Errorf("want a ZeroDivisionError, got: %v", err)
+	}
+	if want := "2 |   ok {{ 1 | divided_by: 0 }}\n  |      ^"; located.Excerpt() != want {
+		t.Errorf("want excerpt: %q, got: %q", want, located.Excerpt())
+	}
+
+	// inline errors only show the line, like Liquid
+	if got, _ := tpl.Render(nil, WithInlineErrors()); got != "\n  ok Liquid error (line 2): divided by 0\n" {
+		t.Errorf("unexpected inline error: %q", got)
+	}
+}
+
+func TestErrorPolicies(t *testing.T) {
+	tpl, err := ParseTemplate("{{ 'a' | upcase }} {{ 1 | divided_by: 0 }}\n{% if true %}{{ 1 | modulo: 0 }}{% endif %} {{ 'b' }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tpl.Render(nil)
+	if !errors.Is(err, ZeroDivisionError{}) || got != "A " {
+		t.Errorf("want the render to abort, got: %q (%v)", got, err)
+	}
+	if got2, err2 := tpl.Render(nil, WithErrorPolicy(AbortOnError)); got2 != got || err2.Error() != err.Error() {
+		t.Errorf("AbortOnError should be the default, got: %q (%v)", got2, err2)
+	}
+
+	got, err = tpl.Render(nil, WithErrorPolicy(RenderErrorsInline))
+	if want := "A Liquid error (line 1): divided by 0\nLiquid error (line 2): divided by 0 b"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+
+	got, err = tpl.Render(nil, WithErrorPolicy(CollectErrors))
+	if want := "A \n b"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+	var collected RenderErrors
+	if !errors.As(err, &collected) || len(collected) != 2 {
+		t.Fatalf("want 2 collected errors, got: %v", err)
+	}
+	var located Error
+	if !errors.As(collected[1], &located) || located.Line != 2 || !errors.Is(err, ZeroDivisionError{}) {
+		t.Errorf("unexpected collected error: %v", collected[1])
+	}
+
+	// the handler is called once for each error, and can abort the render
+	var handled []error
+	got, err = tpl.Render(nil, WithErrorPolicy(RenderErrorsInline), WithErrorHandler(func(err error) (string, error) {
+		handled = append(handled, err)
+		if len(handled) > 1 {
+			return "", errors.New("too many errors")
+		}
+		return "[oops]", nil
+	}))
+	if got != "A [oops]\n" || err == nil || err.Error() != "too many errors" {
+		t.Errorf("unexpected render with a handler: %q (%v)", got, err)
+	}
+	if len(handled) != 2 {
+		t.Errorf("want the handler called twice, got: %v", handled)
+	}
+}
+
+type userDrop struct{}
+
+func (d userDrop) InvokeDrop(key string) interface{} {
+	return "no context"
+}
+
+func (d userDrop) InvokeDropContext(ctx context.Context, key string) interface{} {
+	return ctx.Value(testContextKey)
+}
+
+type contextKey string
+
+const testContextKey = contextKey("name")
+
+func TestRenderContext(t *testing.T) {
+	engine := NewEngine()
+	cancelled := 0
+	engine.RegisterFilter("cancel", func(ctx context.Context, input string, n int) string {
+		if n == cancelled {
+			ctx.Value(contextKey("cancel")).(context.CancelFunc)()
+		}
+		return input
+	})
+	engine.RegisterFilter("greet", func(ctx context.Context, input string) string {
+		return fmt.Sprintf("%v %v", input, ctx.Value(testContextKey))
+	})
+
+	tpl, err := engine.ParseTemplate(`{{ 'a' | cancel: 1 }}{% if true %}{{ 'b' | cancel: 2 }}{{ 'c' }}{% endif %}{{ 'd' }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 2} {
+		cancelled = n
+		goctx, cancel := context.WithCancel(context.Background())
+		goctx = context.WithValue(goctx, contextKey("cancel"), cancel)
+
+		got, err := tpl.RenderContext(goctx, nil, WithInlineErrors())
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("want the render to be cancelled, got: %v", err)
+		}
+		// the block which was cancelled doesn't render
+		if got != "a" {
+			t.Errorf("want the output before the cancellation, got: %q", got)
+		}
+	}
+
+	goctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-goctx.Done()
+	if _, err := tpl.RenderContext(goctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want the deadline to stop the render, got: %v", err)
+	}
+
+	// filters and drops are given the context
+	goctx = context.WithValue(context.Background(), testContextKey, "Ada")
+	tpl, _ = engine.ParseTemplate(`{{ 'Hello' | greet }}, {{ user.name }}`)
+	if got, err := tpl.RenderContext(goctx, Vars{"user": userDrop{}}); err != nil || got != "Hello Ada, Ada" {
+		t.Errorf("want: %q, got: %q (%v)", "Hello Ada, Ada", got, err)
+	}
+	if got, err := tpl.Render(Vars{"user": userDrop{}}); err != nil || got != "Hello <nil>, " {
+		t.Errorf("want: %q, got: %q (%v)", "Hello <nil>, ", got, err)
+	}
+
+	// including drops reached through filters
+	tpl, _ = ParseTemplate(`{{ users | map: 'name' | join }} {{ users | where: 'name', 'Ada' | size }} {{ users | sort: 'name' | size }}`)
+	if got, err := tpl.RenderContext(goctx, Vars{"users": []interface{}{userDrop{}}}); err != nil || got != "Ada 1 1" {
+		t.Errorf("want: %q, got: %q (%v)", "Ada 1 1", got, err)
+	}
+}
+
+// selfTag renders the template it's in again, like a snippet which includes itself
+type selfTag struct{}
+
+func (t selfTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
+	return selfNode{}, nil
+}
+
+type selfNode struct{}
+
+func (n selfNode) Render(ctx *Context) (string, error) {
+	return renderNodes(ctx.template.Nodes, ctx)
+}
+
+func (n selfNode) Blank() bool { return false }
+
+func TestNestingLimits(t *testing.T) {
+	nested := strings.Repeat(`{% if true %}`, 3) + "x" + strings.Repeat(`{% endif %}`, 3)
+
+	if _, err := ParseTemplate(nested, WithNestingLimit(3)); err != nil {
+		t.Errorf("want blocks up to the limit to parse, got: %v", err)
+	}
+	_, err := ParseTemplate(nested, WithNestingLimit(2))
+	var located Error
+	if !errors.Is(err, StackLevelError{Detail{Message: "Nesting too deep"}}) || !errors.As(err, &located) || located.Column != 27 {
+		t.Errorf("want nesting too deep at the third block, got: %v", err)
+	}
+	if _, err := ParseTemplate(strings.Repeat(`{% if true %}`, 101) + strings.Repeat(`{% endif %}`, 101)); !errors.Is(err, StackLevelError{}) {
+		t.Errorf("want the default limit of 100, got: %v", err)
+	}
+
+	tpl, err := ParseTemplate(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpl.Render(nil, WithRecursionLimit(4)); err != nil || got != "x" {
+		t.Errorf("want: %q, got: %q (%v)", "x", got, err)
+	}
+	if _, err := tpl.Render(nil, WithRecursionLimit(3)); !errors.Is(err, StackLevelError{}) {
+		t.Errorf("want nesting too deep, got: %v", err)
+	}
+
+	// recursion stops with an error instead of overflowing the stack
+	engine := NewEngine()
+	engine.RegisterTag("self", selfTag{})
+	tpl, err = engine.ParseTemplate(`a{% self %}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpl.Render(nil); !errors.Is(err, StackLevelError{}) {
+		t.Errorf("want nesting too deep, got: %v", err)
+	}
+	got, err := tpl.Render(nil, WithInlineErrors(), WithRecursionLimit(3))
+	if want := "aaaLiquid error: Nesting too deep"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+}
+
+// counterTag counts how many times it has been rendered, assigning the count to a variable
+type counterTag struct{}
+
+func (t counterTag) Parse(name, markup string, tokenizer *Tokenizer, ctx *ParseContext) (Node, error) {
+	return counterNode{}, nil
+}
+
+type counterNode struct{}
+
+func (n counterNode) Render(ctx *Context) (string, error) {
+	count, _ := ctx.Registers()["count"].(int)
+	count++
+	ctx.Registers()["count"] = count
+	return "", ctx.Assign("count", count)
+}
+
+func (n counterNode) Blank() bool { return true }
+
+func TestConcurrentRender(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterTag("counter", counterTag{})
+	engine.RegisterFilter("greet", func(ctx context.Context, input string) string {
+		return fmt.Sprintf("%v %v", input, ctx.Value(testContextKey))
+	})
+
+	tpl, err := engine.ParseTemplate(`{% counter %}{% counter %}{% if user.admin %}{{ 'Hi' | greet }}{% else %}{{ user.name | upcase }}{% endif %} {{ count }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same vars are used by every render
+	shared := Vars{"user": map[string]interface{}{"name": "bob", "admin": false}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vars, want := shared, "BOB 2"
+			if i%2 == 0 {
+				vars, want = Vars{"user": map[string]interface{}{"admin": true}}, fmt.Sprintf("Hi %v 2", i)
+			}
+
+			goctx := context.WithValue(context.Background(), testContextKey, i)
+			if got, err := tpl.RenderContext(goctx, vars); err != nil || got != want {
+				t.Errorf("want: %q, got: %q (%v)", want, got, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := shared["count"]; ok {
+		t.Errorf("want the vars to be left unchanged, got: %v", shared)
+	}
+}
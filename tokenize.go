@@ -1,11 +1,16 @@
 package liquid
 
-import "io"
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
 
 // Tokenizer allows iteration through a list of tokens
 type Tokenizer struct {
-	tokens []string
-	index  int
+	tokens    []string
+	positions []Position
+	index     int
 }
 
 // Next returns token, if available, and an EOF if the end has been reached
@@ -27,7 +32,19 @@ func (t *Tokenizer) Next() (string, error) {
 
 // NewTokenizer creates a *Tokenizer instance specific to the supplied template
 func NewTokenizer(template string) *Tokenizer {
-	indices := templateParserRegexp.FindAllStringIndex(template, -1)
+	tokenizer, _ := newTokenizer(template, 0)
+	return tokenizer
+}
+
+// newTokenizer creates a *Tokenizer, or an ErrTokenLimit if the template has more
+// than maxTokens tokens. Only enough of the template to tell is split up
+func newTokenizer(template string, maxTokens int) (*Tokenizer, error) {
+	n := -1
+	if maxTokens > 0 {
+		// every match is at least one token
+		n = maxTokens + 1
+	}
+	indices := templateParserRegexp.FindAllStringIndex(template, n)
 
 	var tokens []string
 	var before int
@@ -37,14 +54,42 @@ func NewTokenizer(template string) *Tokenizer {
 		}
 		tokens = append(tokens, template[loc[0]:loc[1]])
 		before = loc[1]
+		if exceeds(len(tokens), maxTokens) {
+			return nil, ErrTokenLimit
+		}
 	}
 
 	if before < len(template) {
 		tokens = append(tokens, template[before:len(template)])
 	}
+	if exceeds(len(tokens), maxTokens) {
+		return nil, ErrTokenLimit
+	}
+
+	// work out where each token starts
+	positions := make([]Position, len(tokens))
+	pos := Position{Line: 1, Column: 1}
+	for i, token := range tokens {
+		positions[i] = pos
+		if newline := strings.LastIndexByte(token, '\n'); newline >= 0 {
+			pos.Line += strings.Count(token, "\n")
+			pos.Column = utf8.RuneCountInString(token[newline+1:]) + 1
+		} else {
+			pos.Column += utf8.RuneCountInString(token)
+		}
+	}
 
 	return &Tokenizer{
-		tokens: tokens,
-		index:  0,
+		tokens:    tokens,
+		positions: positions,
+		index:     0,
+	}, nil
+}
+
+// Position returns where the last token returned by Next starts in the template
+func (t *Tokenizer) Position() Position {
+	if t.index == 0 {
+		return Position{Line: 1, Column: 1}
 	}
+	return t.positions[t.index-1]
 }
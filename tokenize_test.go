@@ -50,3 +50,21 @@ func TestCalculateLineNumbersPerTokenWithProfiling(t *testing.T) {
 	//     assert_equal [1, 2, 2], tokenize_line_numbers("\n{{funk}}\n")
 	//     assert_equal [1, 1, 3], tokenize_line_numbers(" {{\n funk \n}} ")
 }
+
+func TestTokenPositions(t *testing.T) {
+	tokenizer := NewTokenizer("a\n  {{ b }}\tcé {% if c %}\n\n{{d}}")
+	want := []Position{{1, 1}, {2, 3}, {2, 10}, {2, 14}, {2, 24}, {4, 1}}
+
+	var got []Position
+	for {
+		_, err := tokenizer.Next()
+		got = append(got, tokenizer.Position())
+		if err != nil {
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong token positions, want: %v, got: %v", want, got)
+	}
+}
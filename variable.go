@@ -20,14 +20,32 @@ type Variable struct {
 	Name    Expression
 	Filters []Filter
 	markup  string
+	pos     Position
 }
 
-func (v *Variable) Render(vars Vars) (string, error) {
-	panic("unimplemented")
+// Position returns where the variable starts in the template
+func (v *Variable) Position() Position {
+	return v.pos
 }
 
+func (v *Variable) Render(ctx *Context) (string, error) {
+	output, err := v.Name.Evaluate(*ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, filter := range v.Filters {
+		if output, err = filter.apply(output, ctx); err != nil {
+			return "", err
+		}
+	}
+
+	return revealString(output, ctx.options.reflection), nil
+}
+
+// Blank is always false, variables are considered to have output even if they evaluate to nil
 func (v *Variable) Blank() bool {
-	panic("unimplemented")
+	return false
 }
 
 func (v *Variable) String() string {
@@ -53,7 +71,7 @@ func CreateVariable(value string) (*Variable, error) {
 	matches := variableQuotedFragmentRegexp.FindStringSubmatch(value)
 
 	if len(matches) != 2 {
-		return nil, fmt.Errorf("Bad match")
+		return nil, syntaxError("Bad match")
 	}
 
 	return ParseStrict(value)
@@ -63,8 +81,17 @@ func CreateVariable(value string) (*Variable, error) {
 // a parse of a liquid variable expression
 type VariableParser func(markup string) (*Variable, error)
 
-// ParseStrict performs the strictest form of VariableParser parse
+// ParseStrict performs the strictest form of VariableParser parse, the errors
+// of the markup are SyntaxErrors
 func ParseStrict(markup string) (*Variable, error) {
+	v, err := parseStrict(markup)
+	if err != nil {
+		return nil, SyntaxError{Detail{Err: err}}
+	}
+	return v, nil
+}
+
+func parseStrict(markup string) (*Variable, error) {
 	var filters []Filter
 	p, err := NewParser(markup)
 	if err != nil {
@@ -118,14 +145,65 @@ func ParseStrict(markup string) (*Variable, error) {
 	}, nil
 }
 
+// ParseLax is the analog to Liquid::Variable#lax_parse, it picks the name and
+// filters out of the markup with regular expressions, so sloppy markup such as
+// `a | filter arg` is accepted. Anything it can't make sense of is ignored
+func ParseLax(markup string) (*Variable, error) {
+	v := &Variable{Name: Nil, markup: markup}
+
+	matches := markupWithQuotedFragmentRegexp.FindStringSubmatch(markup)
+	if matches == nil {
+		return v, nil
+	}
+	v.Name = ParseExpression(matches[1])
+
+	if filterMarkup := filterMarkupRegexp.FindStringSubmatch(matches[2]); filterMarkup != nil {
+		for _, f := range filterParserRegexp.FindAllString(filterMarkup[1], -1) {
+			filterName := filterNameRegexp.FindString(f)
+			if filterName == "" {
+				continue
+			}
+
+			var filterArgs []string
+			for _, arg := range filterArgsRegexp.FindAllStringSubmatch(f, -1) {
+				filterArgs = append(filterArgs, arg[1])
+			}
+			v.Filters = append(v.Filters, ParseFilterExpressions(filterName, filterArgs))
+		}
+	}
+
+	return v, nil
+}
+
+// parseVariable parses markup with the VariableParser of the error mode. In warn
+// mode markup which only parses laxly is kept, and its strict error is recorded
+// as a warning
+func parseVariable(markup string, ctx *ParseContext) (*Variable, error) {
+	switch ctx.options.errorMode {
+	case LaxMode:
+		return ParseLax(markup)
+	case WarnMode:
+		v, err := CreateVariable(markup)
+		if err != nil {
+			syntaxErr := err.(SyntaxError)
+			syntaxErr.Message = fmt.Sprintf("%v in %q", syntaxErr.message(), "{{"+markup+"}}")
+			ctx.warn(syntaxErr)
+			return ParseLax(markup)
+		}
+		return v, nil
+	}
+	return CreateVariable(markup)
+}
+
 // ParseFilterExpressions parses the filter args passed with a liquid variable
 func ParseFilterExpressions(name string, unparsedArgs []string) Filter {
 	var args []Expression
 	kwargs := make(map[string]Expression)
 
 	for _, a := range unparsedArgs {
-		// Check for keyword arguments first, anything leftover will be treated like a regular argument
-		if submatches := tagAttributesRegexp.FindStringSubmatch(a); len(submatches) > 0 {
+		// Check for keyword arguments first, anything leftover will be treated like a regular argument.
+		// The match must cover the whole argument so that strings like '%H:%M' aren't mistaken for one
+		if submatches := tagAttributesRegexp.FindStringSubmatch(a); len(submatches) > 0 && submatches[0] == a {
 			kwargs[submatches[1]] = ParseExpression(submatches[2])
 		} else {
 			args = append(args, ParseExpression(a))
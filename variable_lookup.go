@@ -18,52 +18,76 @@ type VariableLookup struct {
 	commandFlags uint
 }
 
-func (v *VariableLookup) Evaluate(c Context) Expression {
+// Evaluate looks up the variable and each of its keys. Anything that can't be found
+// is nil, unless strict variables are enabled, in which case it's an UndefinedVariable error
+func (v *VariableLookup) Evaluate(c Context) (Expression, error) {
+
+	name, err := v.name.Evaluate(c)
+	if err != nil {
+		return nil, err
+	}
+	path := name.Name()
 
-	name := v.name.Evaluate(c)
 	object, err := c.FindVariable(name)
 	if err != nil {
-		return nil
+		if c.options.strictVariables {
+			return nil, UndefinedVariable{Name: path}
+		}
+		return Nil, nil
+	}
+	// values the ReflectionPolicy denies are nil, wherever they're found
+	policy := c.options.reflection
+	object = policy.reveal(object)
+
+	for i, lookup := range v.lookups {
+		key, err := lookup.Evaluate(c)
+		if err != nil {
+			return nil, err
+		}
+		path += lookupPath(lookup, key)
+
+		// If object is a hash- or array-like object we look for the
+		// presence of the key and if its available we return it
+		value, ok, err := c.lookupAndEvaluate(object, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			object = policy.reveal(value)
+			continue
+		}
+
+		// Some special cases. If the part wasn't in square brackets and
+		// no key with the same name was found we interpret following calls
+		// as commands and call them on the current object
+		if v.commandFlags&(1<<uint(i)) != 0 {
+			if value, ok := invokeCommand(object, key.Name()); ok {
+				object = policy.reveal(value)
+				continue
+			}
+		}
+
+		// No key was present with the desired value and it wasn't one of the directly supported
+		// keywords either. The only thing we got left is to return nil
+		if c.options.strictVariables {
+			return nil, UndefinedVariable{Name: path}
+		}
+		return Nil, nil
 	}
 
-	return object
-	//         def evaluate(context)
-	//       name = context.evaluate(@name)
-	//       object = context.find_variable(name)
-
-	//       @lookups.each_index do |i|
-	//         key = context.evaluate(@lookups[i])
-
-	//         # If object is a hash- or array-like object we look for the
-	//         # presence of the key and if its available we return it
-	//         if object.respond_to?(:[]) &&
-	//             ((object.respond_to?(:key?) && object.key?(key)) ||
-	//              (object.respond_to?(:fetch) && key.is_a?(Integer)))
-
-	//           # if its a proc we will replace the entry with the proc
-	//           res = context.lookup_and_evaluate(object, key)
-	//           object = res.to_liquid
-
-	//           # Some special cases. If the part wasn't in square brackets and
-	//           # no key with the same name was found we interpret following calls
-	//           # as commands and call them on the current object
-	//         elsif @command_flags & (1 << i) != 0 && object.respond_to?(key)
-	//           object = object.send(key).to_liquid
-
-	//           # No key was present with the desired value and it wasn't one of the directly supported
-	//           # keywords either. The only thing we got left is to return nil or
-	//           # raise an exception if `strict_variables` option is set to true
-	//         else
-	//           return nil unless context.strict_variables
-	//           raise Liquid::UndefinedVariable, "undefined variable #{key}"
-	//         end
-
-	//         # If we are dealing with a drop here we have to
-	//         object.context = context if object.respond_to?(:context=)
-	//       end
-
-	//       object
-	// end
+	return object, nil
+}
+
+// lookupPath describes a lookup for error messages, as .key for
+// dotted lookups and [key] for those in square brackets
+func lookupPath(lookup, key Expression) string {
+	if _, ok := lookup.(literalExpr); ok {
+		return "." + key.Name()
+	}
+	if s, ok := key.(stringExpr); ok {
+		return fmt.Sprintf("['%v']", string(s))
+	}
+	return fmt.Sprintf("[%v]", toString(key))
 }
 
 func (v *VariableLookup) Name() string {
@@ -78,7 +102,6 @@ func ParseVariableLookup(markup string) *VariableLookup {
 	lookups := variableParserRegexp.FindAllString(markup, -1)
 
 	if len(lookups) == 0 {
-		fmt.Println("No lookups found, returning nil")
 		return nil
 	}
 
@@ -96,12 +119,13 @@ func ParseVariableLookup(markup string) *VariableLookup {
 	lookupExpressions := make([]Expression, len(lookups)-1)
 
 	for i, lookup := range lookups[1:] {
-		if m := squareBracketedRegexp.FindStringSubmatch(lookups[0]); len(m) == 2 {
-			name = ParseExpression(m[1])
-		} else {
-			lookupExpressions[i] = literalExpr(lookup)
+		if m := squareBracketedRegexp.FindStringSubmatch(lookup); len(m) == 2 {
+			lookupExpressions[i] = ParseExpression(m[1])
+			continue
 		}
 
+		lookupExpressions[i] = literalExpr(lookup)
+
 		for _, command := range commandMethods {
 			if lookup == command {
 				commandFlags |= 1 << uint(i)
@@ -1,6 +1,7 @@
 package liquid
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -37,6 +38,23 @@ func checkVariable(t *testing.T, markup, wantName string, wantFilters []Filter,
 	return v
 }
 
+// checkLaxVariable checks the name and filters of markup parsed with ParseLax
+func checkLaxVariable(t *testing.T, markup, wantName string, wantFilters []Filter) {
+	v, err := ParseLax(markup)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if v.Name.Name() != wantName {
+		t.Errorf("%v name mismatched, want: %v, got: %v", markup, wantName, v.Name.Name())
+	}
+
+	if !reflect.DeepEqual(v.Filters, wantFilters) {
+		t.Errorf("%v filters mismatched, want: %v, got: %v", markup, wantFilters, v.Filters)
+	}
+}
+
 func TestVariable(t *testing.T) {
 	checkVariable(t, "hello", "hello", nil, true)
 }
@@ -96,12 +114,12 @@ func TestFiltersWithoutWhitespace(t *testing.T) {
 	}, true)
 }
 
-// XXX: This requires a lax parser, which we don't have
-// func TestSymbol(t *testing.T) {
-// 	checkVariable(t, "http://disney.com/logo.gif | image: 'med' ", "http://disney.com/logo.gif", []Filter{
-// 		{name: "image", args: []Expression{"med"}},
-// 	}, true)
-// }
+func TestSymbol(t *testing.T) {
+	// the name is a lookup of http with the rest of the url as keys
+	checkLaxVariable(t, "http://disney.com/logo.gif | image: 'med' ", "http", []Filter{
+		{name: "image", args: []Expression{stringExpr("med")}},
+	})
+}
 
 func TestStringToFilter(t *testing.T) {
 	checkVariable(t, "'http://disney.com/logo.gif' | image: 'med' ", "http://disney.com/logo.gif", []Filter{
@@ -173,12 +191,32 @@ func TestFilterWithKeywordArguments(t *testing.T) {
 	}, true)
 }
 
-// XXX: lax parsing is not implemented
-//   def test_lax_filter_argument_parsing
-//     var = create_variable(%( number_of_comments | pluralize: 'comment': 'comments' ), error_mode: :lax)
-//     assert_equal VariableLookup.new('number_of_comments'), var.name
-//     assert_equal [['pluralize', ['comment', 'comments']]], var.filters
-//   end
+func TestFilterArgumentContainingColon(t *testing.T) {
+	checkVariable(t, `time | date: '%H:%M'`, "time", []Filter{
+		Filter{
+			name: "date",
+			args: []Expression{stringExpr("%H:%M")},
+		},
+	}, true)
+}
+
+func TestLaxFilterArgumentParsing(t *testing.T) {
+	checkLaxVariable(t, " number_of_comments | pluralize: 'comment': 'comments' ", "number_of_comments", []Filter{
+		{name: "pluralize", args: []Expression{stringExpr("comment"), stringExpr("comments")}},
+	})
+	checkLaxVariable(t, "a | filter arg | upcase", "a", []Filter{
+		{name: "filter"},
+		{name: "upcase"},
+	})
+	checkLaxVariable(t, "a | b: x: 1, 2 ,", "a", []Filter{
+		{name: "b", args: []Expression{integerExpr(2)}, kwargs: map[string]Expression{"x": integerExpr(1)}},
+	})
+
+	v, err := ParseLax(" | ")
+	if err != nil || v.Name != Nil || v.Filters != nil {
+		t.Errorf("empty markup should have a nil name and no filters, got: %v (%v)", v, err)
+	}
+}
 
 func TestStringFilterArgumentParsing(t *testing.T) {
 	_, err := CreateVariable("number_of_comments | pluralize: 'comment': 'comments'")
@@ -317,3 +355,119 @@ func checkTemplateRender(t *testing.T, template string, vars map[string]interfac
 //   def test_multiline_variable
 //     assert_equal 'worked', Template.parse("{{\ntest\n}}").render!('test' => 'worked')
 //   end
+
+func TestVariableLookupEvaluate(t *testing.T) {
+	ctx := Context{
+		scopes: scopeStack{
+			Vars{
+				"product": map[string]interface{}{
+					"title": "Shoes",
+					"tags":  []interface{}{"sale", "summer"},
+					"size":  "large",
+				},
+				"key":  "title",
+				"drop": emptinessDrop{true},
+			},
+		},
+	}
+
+	tests := []struct {
+		markup string
+		want   Expression
+	}{
+		{"product.title", stringExpr("Shoes")},
+		{"product['title']", stringExpr("Shoes")},
+		{"product[key]", stringExpr("Shoes")},
+		{"product.tags[0]", stringExpr("sale")},
+		{"product.tags[-1]", stringExpr("summer")},
+		{"product.tags[5]", Nil},
+		{"product.tags.first", stringExpr("sale")},
+		{"product.tags.last", stringExpr("summer")},
+		{"product.tags.size", integerExpr(2)},
+		{"product.title.size", integerExpr(5)},
+		// keys take precedence over commands
+		{"product.size", stringExpr("large")},
+		{"product.vendor", Nil},
+		{"product.vendor.name", Nil},
+		{"drop.empty?", True},
+		{"missing", Nil},
+	}
+
+	for _, test := range tests {
+		got, err := ParseVariableLookup(test.markup).Evaluate(ctx)
+		if err != nil {
+			t.Errorf("%v failed to evaluate: %v", test.markup, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v evaluated wrong, want: %#v, got: %#v", test.markup, test.want, got)
+		}
+	}
+}
+
+func TestStrictVariables(t *testing.T) {
+	vars := Vars{
+		"product": map[string]interface{}{
+			"title":  "Shoes",
+			"vendor": map[string]interface{}{},
+			"tags":   []interface{}{"sale"},
+			"price":  nil,
+		},
+		"key": "colour",
+	}
+
+	tests := []struct {
+		markup string
+		want   string
+	}{
+		{`{{ product.title }}`, "Shoes"},
+		{`{{ product.tags.size }}`, "1"},
+		{`{{ product.tags[3] }}`, ""},
+		{`{{ product.price }}`, ""},
+		{`{% if product.title == 'Shoes' %}yes{% endif %}`, "yes"},
+	}
+	for _, test := range tests {
+		tpl, err := ParseTemplate(test.markup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := tpl.Render(vars, WithStrictVariables()); err != nil || got != test.want {
+			t.Errorf("%v want: %q, got: %q (%v)", test.markup, test.want, got, err)
+		}
+	}
+
+	undefined := []struct {
+		markup string
+		path   string
+	}{
+		{`{{ missing }}`, "missing"},
+		{`{{ product.vendor.name }}`, "product.vendor.name"},
+		{`{{ product.vendor.name.first }}`, "product.vendor.name"},
+		{`{{ product['sku'] }}`, "product['sku']"},
+		{`{{ product[key] }}`, "product['colour']"},
+		{`{{ product.price.amount }}`, "product.price.amount"},
+		{`{{ product.title | append: missing }}`, "missing"},
+		{`{% if product.vendor.name %}yes{% endif %}`, "product.vendor.name"},
+		{`{% if product.title == other %}yes{% endif %}`, "other"},
+	}
+	for _, test := range undefined {
+		tpl, err := ParseTemplate(test.markup)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tpl.Render(vars, WithStrictVariables())
+		if !errors.Is(err, UndefinedVariable{Name: test.path}) {
+			t.Errorf("%v want an undefined variable error for %v, got: %v", test.markup, test.path, err)
+		}
+
+		// without strict variables they're nil
+		if _, err := tpl.Render(vars); err != nil {
+			t.Errorf("%v failed to render: %v", test.markup, err)
+		}
+	}
+
+	tpl, _ := ParseTemplate(`a{{ product.vendor.name }}b`)
+	got, err := tpl.Render(vars, WithStrictVariables(), WithInlineErrors())
+	if want := "aLiquid error (line 1): undefined variable product.vendor.nameb"; err != nil || got != want {
+		t.Errorf("want: %q, got: %q (%v)", want, got, err)
+	}
+}